@@ -3,6 +3,7 @@ package gocache
 import (
 	"context"
 	"errors"
+	"time"
 )
 
 var (
@@ -12,6 +13,7 @@ var (
 	ExpiryDeviation = 0.05
 
 	ErrRecordNotFound = errors.New("record not found")
+	ErrNotSupported   = errors.New("operation not supported")
 )
 
 type Cache[T any] interface {
@@ -19,3 +21,44 @@ type Cache[T any] interface {
 	Get(ctx context.Context, key string) (T, error)
 	Delete(ctx context.Context, key string) error
 }
+
+// AtomicCache is a cache that supports atomic read-modify-write operations
+type AtomicCache[T any] interface {
+	Cache[T]
+	// SetNX sets the value only if the key does not exist, it reports
+	// whether the value was set
+	SetNX(ctx context.Context, key string, value T) (bool, error)
+	// GetOrSet returns the existing value of the key if present, otherwise it
+	// sets and returns the given value. loaded is true if the value was
+	// already present
+	GetOrSet(ctx context.Context, key string, value T) (actual T, loaded bool, err error)
+	// CompareAndSwap sets the key to new only if its current value equals
+	// old, it reports whether the swap happened
+	CompareAndSwap(ctx context.Context, key string, old, new T) (bool, error)
+}
+
+// VersionedCache is a cache whose entries carry a version that increases on
+// every write, so writers can detect concurrent updates. Version 0 means that
+// the key has no versioned value, either because it does not exist or
+// because it was written before versioning was available
+type VersionedCache[T any] interface {
+	Cache[T]
+	// GetVersioned returns the value of the key along with its version
+	GetVersioned(ctx context.Context, key string) (T, uint64, error)
+	// SetIfVersion sets the value only if the current version of the key
+	// equals version, it reports whether the value was set
+	SetIfVersion(ctx context.Context, key string, value T, version uint64) (bool, error)
+}
+
+// TTLCache is a cache that can tell and set the remaining lifetime of its
+// entries, ChainCache uses it to backfill the previous caches with the
+// remaining lifetime of a value instead of their full expiration
+type TTLCache[T any] interface {
+	Cache[T]
+	// GetWithTTL returns the value of the key along with its remaining
+	// lifetime, 0 if the cache can't tell it
+	GetWithTTL(ctx context.Context, key string) (T, time.Duration, error)
+	// SetWithTTL sets the value to expire after ttl, capped by the expiration
+	// of the cache. A ttl <= 0 uses the expiration of the cache
+	SetWithTTL(ctx context.Context, key string, value T, ttl time.Duration) error
+}
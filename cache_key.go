@@ -1,27 +1,264 @@
 package gocache
 
 import (
+	"bytes"
 	"crypto/md5"
+	"encoding/binary"
 	"fmt"
+	"hash"
+	"hash/fnv"
+	"math"
 	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unsafe"
+
+	"github.com/cespare/xxhash/v2"
 )
 
 type CacheKeyGenerator interface {
 	GetCacheKey() string
 }
 
+// KeyHash selects the hash of the keys generated from structured arguments
+type KeyHash int
+
+const (
+	MD5KeyHash KeyHash = iota
+	XXHashKeyHash
+	FNVKeyHash
+)
+
+// DefaultKeyEncoder generates the keys of GenerateCacheKey. It can only be
+// replaced during initialization, before any key is generated, for example
+// with NewKeyEncoder(WithLegacyKeys()) to keep the keys generated by earlier
+// versions. Use WithKeyEncoder to change the keys of a single cache.
+var DefaultKeyEncoder = NewKeyEncoder()
+
+// KeyEncoder generates cache keys from arbitrary values by walking them
+// reflectively, so that equal values get equal keys: pointers are followed
+// instead of hashing their address, map entries are sorted, times are
+// compared as instants, struct fields tagged `cache:"-"` are ignored, and
+// String methods are not called. Unexported fields are encoded like exported
+// ones, so that values differing only in them get different keys.
+type KeyEncoder struct {
+	hash   KeyHash
+	legacy bool
+}
+
+type KeyEncoderOption func(*KeyEncoder)
+
+// WithKeyHash sets the hash of the encoded values, default MD5KeyHash
+func WithKeyHash(hash KeyHash) KeyEncoderOption {
+	return func(e *KeyEncoder) {
+		e.hash = hash
+	}
+}
+
+// WithLegacyKeys hashes the fmt representation of the values with md5 like
+// earlier versions did, so that keys stored by them are still found
+func WithLegacyKeys() KeyEncoderOption {
+	return func(e *KeyEncoder) {
+		e.legacy = true
+	}
+}
+
+func NewKeyEncoder(options ...KeyEncoderOption) *KeyEncoder {
+	e := &KeyEncoder{}
+	for _, option := range options {
+		option(e)
+	}
+
+	return e
+}
+
 // GenerateCacheKey returns the cache key for the given key object by returning
 // the key if type is string or by computing a checksum of key structure
 // if its type is other than string
 func GenerateCacheKey(arg any) string {
+	return generateCacheKey(DefaultKeyEncoder, arg)
+}
+
+// generateCacheKey is GenerateCacheKey with the given encoder
+func generateCacheKey(encoder *KeyEncoder, arg any) string {
 	switch v := arg.(type) {
 	case CacheKeyGenerator:
 		return v.GetCacheKey()
 	case string:
 		return v
 	default:
+		return encoder.Key(arg)
+	}
+}
+
+// Key returns the hex encoded hash of the value
+func (e *KeyEncoder) Key(arg any) string {
+	if e.legacy {
 		return checksum(arg)
 	}
+
+	var digester hash.Hash
+	switch e.hash {
+	case XXHashKeyHash:
+		digester = xxhash.New()
+	case FNVKeyHash:
+		digester = fnv.New64a()
+	default:
+		digester = md5.New()
+	}
+
+	buffer := &bytes.Buffer{}
+	value := reflect.ValueOf(arg)
+	for value.Kind() == reflect.Pointer && !value.IsNil() {
+		value = value.Elem()
+	}
+
+	if value.IsValid() {
+		buffer.WriteString(value.Type().String())
+		value = addressable(value)
+	}
+	encodeKey(buffer, value, nil)
+
+	digester.Write(buffer.Bytes())
+	return fmt.Sprintf("%x", digester.Sum(nil))
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// encodeKey writes an unambiguous representation of the value, visiting
+// holds the pointers being encoded to stop on cycles
+func encodeKey(buffer *bytes.Buffer, value reflect.Value, visiting []uintptr) {
+	if !value.IsValid() {
+		buffer.WriteString("n")
+		return
+	}
+
+	switch value.Kind() {
+	case reflect.Pointer:
+		if value.IsNil() {
+			buffer.WriteString("n")
+			return
+		}
+
+		if slices.Contains(visiting, value.Pointer()) {
+			buffer.WriteString("c")
+			return
+		}
+		encodeKey(buffer, value.Elem(), append(visiting, value.Pointer()))
+	case reflect.Interface:
+		if value.IsNil() {
+			buffer.WriteString("n")
+			return
+		}
+
+		// the dynamic type tells apart equal representations of different types
+		writeKeyString(buffer, "i", value.Elem().Type().String())
+		encodeKey(buffer, value.Elem(), visiting)
+	case reflect.Bool:
+		buffer.WriteString(strconv.FormatBool(value.Bool()))
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		buffer.WriteString("d")
+		buffer.WriteString(strconv.FormatInt(value.Int(), 10))
+		buffer.WriteString(";")
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		buffer.WriteString("u")
+		buffer.WriteString(strconv.FormatUint(value.Uint(), 10))
+		buffer.WriteString(";")
+	case reflect.Float32, reflect.Float64:
+		buffer.WriteString("f")
+		buffer.Write(binary.BigEndian.AppendUint64(nil, math.Float64bits(value.Float())))
+	case reflect.Complex64, reflect.Complex128:
+		buffer.WriteString("x")
+		buffer.Write(binary.BigEndian.AppendUint64(nil, math.Float64bits(real(value.Complex()))))
+		buffer.Write(binary.BigEndian.AppendUint64(nil, math.Float64bits(imag(value.Complex()))))
+	case reflect.String:
+		writeKeyString(buffer, "s", value.String())
+	case reflect.Slice, reflect.Array:
+		if value.Kind() == reflect.Slice && value.Type().Elem().Kind() == reflect.Uint8 {
+			writeKeyString(buffer, "b", string(value.Bytes()))
+			return
+		}
+
+		buffer.WriteString("[" + strconv.Itoa(value.Len()) + ":")
+		for i := 0; i < value.Len(); i++ {
+			encodeKey(buffer, value.Index(i), visiting)
+		}
+		buffer.WriteString("]")
+	case reflect.Map:
+		// entries are sorted by their encoded keys
+		entries := make([][2][]byte, 0, value.Len())
+		iterator := value.MapRange()
+		for iterator.Next() {
+			key, element := &bytes.Buffer{}, &bytes.Buffer{}
+			encodeKey(key, iterator.Key(), visiting)
+			encodeKey(element, iterator.Value(), visiting)
+			entries = append(entries, [2][]byte{key.Bytes(), element.Bytes()})
+		}
+		slices.SortFunc(entries, func(a, b [2][]byte) int {
+			return bytes.Compare(a[0], b[0])
+		})
+
+		buffer.WriteString("{" + strconv.Itoa(len(entries)) + ":")
+		for _, entry := range entries {
+			buffer.Write(entry[0])
+			buffer.Write(entry[1])
+		}
+		buffer.WriteString("}")
+	case reflect.Struct:
+		if value.Type() == timeType {
+			// the same instant in any location gets the same key
+			writeKeyString(buffer, "t", value.Interface().(time.Time).UTC().Format(time.RFC3339Nano))
+			return
+		}
+
+		// map values and interface elements are not addressable
+		value = addressable(value)
+
+		buffer.WriteString("(")
+		for i := 0; i < value.NumField(); i++ {
+			field := value.Type().Field(i)
+			if field.Tag.Get("cache") == "-" {
+				continue
+			}
+
+			fieldValue := value.Field(i)
+			if !field.IsExported() {
+				// unexported fields are read through their address, so that
+				// the values in them can be used like exported ones
+				fieldValue = reflect.NewAt(field.Type, unsafe.Pointer(fieldValue.UnsafeAddr())).Elem()
+			}
+
+			writeKeyString(buffer, "", field.Name)
+			encodeKey(buffer, fieldValue, visiting)
+		}
+		buffer.WriteString(")")
+	default:
+		// functions, channels and unsafe pointers are encoded by type only
+		writeKeyString(buffer, "?", value.Type().String())
+	}
+}
+
+// addressable returns the value itself if it is addressable, or an addressable
+// copy of it
+func addressable(value reflect.Value) reflect.Value {
+	if value.CanAddr() {
+		return value
+	}
+
+	copied := reflect.New(value.Type()).Elem()
+	copied.Set(value)
+	return copied
+}
+
+// writeKeyString writes a length prefixed string, so that adjacent strings
+// cannot be confused with each other
+func writeKeyString(buffer *bytes.Buffer, tag, s string) {
+	buffer.WriteString(tag)
+	buffer.WriteString(strconv.Itoa(len(s)))
+	buffer.WriteString(":")
+	buffer.WriteString(s)
 }
 
 // checksum hashes a given object into a string
@@ -33,3 +270,144 @@ func checksum(arg any) string {
 
 	return fmt.Sprintf("%x", hash)
 }
+
+// keyFunction returns the key function set by WithKeyFunc, or
+// GenerateCacheKey with the encoder set by WithKeyEncoder if none is set
+func keyFunction[T any](config *LoadConfig) KeyFunction[T] {
+	if config.keyFunc == nil {
+		if config.keyEncoder != nil {
+			encoder := config.keyEncoder
+			return func(arg T) string {
+				return generateCacheKey(encoder, arg)
+			}
+		}
+
+		return func(arg T) string {
+			return GenerateCacheKey(arg)
+		}
+	}
+
+	fn, ok := config.keyFunc.(KeyFunction[T])
+	if !ok {
+		panic(fmt.Sprintf("gocache: key function must be a %T", fn))
+	}
+
+	return fn
+}
+
+// KeyTemplate returns a key function filling the placeholders of the template
+// with the fields of the argument, e.g. "user:{ID}:{Locale}" or
+// "order:{Customer.ID}:{Number}" for a struct or a pointer to a struct. It
+// panics if the template refers to a field that T does not have.
+func KeyTemplate[T any](template string) KeyFunction[T] {
+	type segment struct {
+		literal string
+		field   []int
+	}
+
+	typ := reflect.TypeFor[T]()
+	for typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	if typ.Kind() != reflect.Struct {
+		panic(fmt.Sprintf("gocache: KeyTemplate argument must be a struct, got %v", typ))
+	}
+
+	var segments []segment
+	for rest := template; rest != ""; {
+		start := strings.IndexByte(rest, '{')
+		if start < 0 {
+			segments = append(segments, segment{literal: rest})
+			break
+		}
+
+		end := strings.IndexByte(rest[start:], '}')
+		if end < 0 {
+			panic(fmt.Sprintf("gocache: KeyTemplate %q has an unclosed placeholder", template))
+		}
+
+		if start > 0 {
+			segments = append(segments, segment{literal: rest[:start]})
+		}
+
+		var index []int
+		fieldType := typ
+		for _, name := range strings.Split(rest[start+1:start+end], ".") {
+			for fieldType.Kind() == reflect.Pointer {
+				fieldType = fieldType.Elem()
+			}
+
+			var field reflect.StructField
+			found := fieldType.Kind() == reflect.Struct
+			if found {
+				field, found = fieldType.FieldByName(name)
+			}
+			if !found || !field.IsExported() {
+				panic(fmt.Sprintf("gocache: KeyTemplate %q refers to unknown field %s of %v", template, name, typ))
+			}
+
+			index = append(index, field.Index...)
+			fieldType = field.Type
+		}
+
+		segments = append(segments, segment{field: index})
+		rest = rest[start+end+1:]
+	}
+
+	return func(arg T) string {
+		value := reflect.ValueOf(arg)
+		for value.Kind() == reflect.Pointer && !value.IsNil() {
+			value = value.Elem()
+		}
+
+		var builder strings.Builder
+		for _, segment := range segments {
+			if segment.field == nil {
+				builder.WriteString(segment.literal)
+				continue
+			}
+
+			if value.Kind() != reflect.Struct {
+				continue
+			}
+
+			// a nil pointer on the way leaves the placeholder empty
+			field, err := value.FieldByIndexErr(segment.field)
+			if err != nil {
+				continue
+			}
+			for field.Kind() == reflect.Pointer && !field.IsNil() {
+				field = field.Elem()
+			}
+
+			writeKeyField(&builder, field)
+		}
+
+		return builder.String()
+	}
+}
+
+// writeKeyField writes the readable representation of a key template field
+func writeKeyField(builder *strings.Builder, value reflect.Value) {
+	switch value.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if value.IsNil() {
+			return
+		}
+		fmt.Fprint(builder, value.Interface())
+	case reflect.String:
+		builder.WriteString(value.String())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		builder.WriteString(strconv.FormatInt(value.Int(), 10))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		builder.WriteString(strconv.FormatUint(value.Uint(), 10))
+	case reflect.Bool:
+		builder.WriteString(strconv.FormatBool(value.Bool()))
+	default:
+		if value.Type() == timeType {
+			builder.WriteString(value.Interface().(time.Time).UTC().Format(time.RFC3339Nano))
+			return
+		}
+		fmt.Fprint(builder, value.Interface())
+	}
+}
@@ -0,0 +1,180 @@
+package gocache
+
+import (
+	"testing"
+	"time"
+)
+
+type keyRequest struct {
+	ID      int64
+	Tags    []string
+	Filters map[string]int
+	Since   time.Time
+	Trace   string `cache:"-"`
+	Next    *keyRequest
+	secret  string
+}
+
+func (r keyRequest) String() string {
+	return r.secret
+}
+
+type unexportedRequest struct {
+	id      int64
+	tags    []string
+	filters map[string]any
+	since   time.Time
+}
+
+func TestGenerateCacheKey(t *testing.T) {
+	since := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	shanghai := time.FixedZone("CST", 8*60*60)
+
+	now := time.Now()
+	base := &keyRequest{ID: 1, Tags: []string{"a", "b"}, Filters: map[string]int{"x": 1, "y": 2}, Since: since}
+
+	tests := []struct {
+		name  string
+		a, b  any
+		equal bool
+	}{
+		{"pointers to equal values", base, &keyRequest{ID: 1, Tags: []string{"a", "b"}, Filters: map[string]int{"y": 2, "x": 1}, Since: since}, true},
+		{"pointer and value", base, *base, true},
+		{"same instant in another location", base, &keyRequest{ID: 1, Tags: []string{"a", "b"}, Filters: map[string]int{"x": 1, "y": 2}, Since: since.In(shanghai)}, true},
+		{"ignored field", base, &keyRequest{ID: 1, Tags: []string{"a", "b"}, Filters: map[string]int{"x": 1, "y": 2}, Since: since, Trace: "t1"}, true},
+		{"different unexported field", base, &keyRequest{ID: 1, Tags: []string{"a", "b"}, Filters: map[string]int{"x": 1, "y": 2}, Since: since, secret: "s"}, false},
+		{"only unexported fields", unexportedRequest{id: 1}, unexportedRequest{id: 2}, false},
+		{"different unexported collections", unexportedRequest{tags: []string{"a"}, filters: map[string]any{"x": 1}}, unexportedRequest{tags: []string{"a"}, filters: map[string]any{"x": 2}}, false},
+		{"same unexported instant in another location", unexportedRequest{since: since}, unexportedRequest{since: since.In(shanghai)}, true},
+		{"different unexported instant", unexportedRequest{since: since}, unexportedRequest{since: since.Add(time.Nanosecond)}, false},
+		{"same unexported instant in a map", unexportedRequest{filters: map[string]any{"t": since}}, unexportedRequest{filters: map[string]any{"t": since.In(shanghai)}}, true},
+		{"same unexported instant in a map value", unexportedRequest{filters: map[string]any{"r": unexportedRequest{since: since}}}, unexportedRequest{filters: map[string]any{"r": unexportedRequest{since: since.In(shanghai)}}}, true},
+		{"different unexported instant in a map value", unexportedRequest{filters: map[string]any{"r": unexportedRequest{since: since}}}, unexportedRequest{filters: map[string]any{"r": unexportedRequest{since: since.Add(time.Second)}}}, false},
+		{"monotonic reading", unexportedRequest{since: now}, unexportedRequest{since: now.Round(0)}, true},
+		{"String method", keyRequest{ID: 1, secret: "s"}, keyRequest{ID: 2, secret: "s"}, false},
+		{"different field", base, &keyRequest{ID: 2, Tags: []string{"a", "b"}, Filters: map[string]int{"x": 1, "y": 2}, Since: since}, false},
+		{"different slice split", &keyRequest{Tags: []string{"ab", "c"}}, &keyRequest{Tags: []string{"a", "bc"}}, false},
+		{"different map", base, &keyRequest{ID: 1, Tags: []string{"a", "b"}, Filters: map[string]int{"x": 1}, Since: since}, false},
+		{"different nested pointer", &keyRequest{Next: &keyRequest{ID: 1}}, &keyRequest{Next: &keyRequest{ID: 2}}, false},
+		{"different types", int32(1), int64(1), false},
+		{"different interface types", []any{int32(1)}, []any{int64(1)}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b := GenerateCacheKey(tt.a), GenerateCacheKey(tt.b)
+			if (a == b) != tt.equal {
+				t.Errorf("GenerateCacheKey() got = %v and %v, want equal = %v", a, b, tt.equal)
+			}
+		})
+	}
+}
+
+func TestGenerateCacheKey_Cycle(t *testing.T) {
+	r := &keyRequest{ID: 1}
+	r.Next = r
+
+	if GenerateCacheKey(r) == "" {
+		t.Errorf("GenerateCacheKey() got an empty key")
+	}
+}
+
+func TestKeyEncoder_Key(t *testing.T) {
+	arg := keyRequest{ID: 1}
+
+	tests := []struct {
+		name    string
+		encoder *KeyEncoder
+		want    string
+	}{
+		{"md5", NewKeyEncoder(), NewKeyEncoder(WithKeyHash(MD5KeyHash)).Key(arg)},
+		{"legacy", NewKeyEncoder(WithLegacyKeys()), checksum(arg)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.encoder.Key(arg); got != tt.want {
+				t.Errorf("KeyEncoder.Key() got = %v, want = %v", got, tt.want)
+			}
+		})
+	}
+
+	lengths := []struct {
+		name string
+		hash KeyHash
+		want int
+	}{
+		{"md5", MD5KeyHash, 32},
+		{"xxhash", XXHashKeyHash, 16},
+		{"fnv", FNVKeyHash, 16},
+	}
+	for _, tt := range lengths {
+		t.Run(tt.name+" length", func(t *testing.T) {
+			if got := NewKeyEncoder(WithKeyHash(tt.hash)).Key(arg); len(got) != tt.want {
+				t.Errorf("KeyEncoder.Key() got = %v, want length = %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestKeyTemplate(t *testing.T) {
+	type customer struct {
+		ID string
+	}
+
+	type order struct {
+		Number   int
+		Paid     bool
+		Customer *customer
+		Placed   time.Time
+	}
+
+	placed := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		template string
+		arg      *order
+		want     string
+	}{
+		{"fields", "order:{Number}:{Paid}", &order{Number: 12, Paid: true}, "order:12:true"},
+		{"nested field", "customer:{Customer.ID}:order:{Number}", &order{Number: 12, Customer: &customer{ID: "c1"}}, "customer:c1:order:12"},
+		{"nil pointer", "customer:{Customer.ID}", &order{}, "customer:"},
+		{"nil argument", "order:{Number}", nil, "order:"},
+		{"time", "{Placed}", &order{Placed: placed}, "2024-01-02T03:04:05Z"},
+		{"literal only", "orders", &order{}, "orders"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := KeyTemplate[*order](tt.template)(tt.arg); got != tt.want {
+				t.Errorf("KeyTemplate() got = %v, want = %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestKeyTemplate_Invalid(t *testing.T) {
+	type user struct {
+		ID     int64
+		locale string
+	}
+
+	tests := []struct {
+		name     string
+		template string
+	}{
+		{"unknown field", "user:{Name}"},
+		{"unexported field", "user:{locale}"},
+		{"unclosed placeholder", "user:{ID"},
+		{"field of a non struct", "user:{ID.Value}"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("KeyTemplate(%q) should panic", tt.template)
+				}
+			}()
+
+			KeyTemplate[user](tt.template)
+		})
+	}
+}
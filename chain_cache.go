@@ -2,6 +2,10 @@ package gocache
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
 )
 
 type ChainCacheValue[T any] struct {
@@ -10,68 +14,449 @@ type ChainCacheValue[T any] struct {
 	Value  T
 }
 
+// TierFailurePolicy decides how ChainCache handles the errors of a tier other
+// than ErrRecordNotFound
+type TierFailurePolicy int
+
+const (
+	// the operation stops and returns the error, the default
+	FailFast TierFailurePolicy = iota
+	// a failing read is treated as a miss and a failing write or delete does
+	// not stop the other tiers, the errors are joined into the returned error
+	SkipOnError
+	// like SkipOnError, but the errors of the tier are never returned
+	BestEffort
+)
+
+type ChainCacheConfig struct {
+	// failure policies of the tiers by index, FailFast if not set
+	Policies map[int]TierFailurePolicy
+	// health checks of the tiers by index
+	HealthChecks map[int]TierHealthCheck
+	// backfill the previous tiers in the background instead of before Get
+	// returns
+	AsyncBackfill bool
+	// indexes of the tiers that are not backfilled
+	SkipBackfill map[int]bool
+}
+
+// TierHealthCheck bypasses a tier after it failed, until Check reports it
+// healthy again
+type TierHealthCheck struct {
+	// returns nil if the tier is healthy, a nil Check retries the tier itself
+	Check func(ctx context.Context) error
+	// minimum time between two checks of an unhealthy tier
+	Interval time.Duration
+}
+
+type ChainCacheOption func(*ChainCacheConfig)
+
+// WithTierPolicy sets the failure policy of the tier at index
+func WithTierPolicy(index int, policy TierFailurePolicy) ChainCacheOption {
+	return func(c *ChainCacheConfig) {
+		if c.Policies == nil {
+			c.Policies = make(map[int]TierFailurePolicy)
+		}
+		c.Policies[index] = policy
+	}
+}
+
+// WithTierHealthCheck bypasses the tier at index once it fails. While it is
+// bypassed, reads skip it, writes and deletes don't reach it, and check is
+// called at most once per interval until it returns nil. Values written while
+// the tier is bypassed are missing from it, and deleted values may still be
+// served by it until they expire.
+func WithTierHealthCheck(index int, check func(ctx context.Context) error, interval time.Duration) ChainCacheOption {
+	if interval <= 0 {
+		panic("gocache: WithTierHealthCheck interval must be positive")
+	}
+
+	return func(c *ChainCacheConfig) {
+		if c.HealthChecks == nil {
+			c.HealthChecks = make(map[int]TierHealthCheck)
+		}
+		c.HealthChecks[index] = TierHealthCheck{Check: check, Interval: interval}
+	}
+}
+
+// WithAsyncBackfill writes a value found in a tier to the previous tiers in
+// the background, so that Get returns without waiting for them
+func WithAsyncBackfill() ChainCacheOption {
+	return func(c *ChainCacheConfig) {
+		c.AsyncBackfill = true
+	}
+}
+
+// WithoutBackfill stops writing the values found in the next tiers to the tier
+// at index, e.g. for a tier that is filled by other means
+func WithoutBackfill(index int) ChainCacheOption {
+	return func(c *ChainCacheConfig) {
+		if c.SkipBackfill == nil {
+			c.SkipBackfill = make(map[int]bool)
+		}
+		c.SkipBackfill[index] = true
+	}
+}
+
+// chainTier holds the failure handling of a tier of ChainCache
+type chainTier struct {
+	policy      TierFailurePolicy
+	healthCheck *TierHealthCheck
+	noBackfill  bool
+	unhealthy   atomic.Bool
+	// unix nanoseconds after which an unhealthy tier is checked again
+	nextCheck atomic.Int64
+}
+
+// available reports whether the tier can be used, an unhealthy tier is
+// checked by a single caller once its interval elapsed
+func (t *chainTier) available(ctx context.Context) bool {
+	if !t.unhealthy.Load() {
+		return true
+	}
+
+	now := time.Now().UnixNano()
+	next := t.nextCheck.Load()
+	if now < next || !t.nextCheck.CompareAndSwap(next, now+int64(t.healthCheck.Interval)) {
+		return false
+	}
+
+	if t.healthCheck.Check != nil && t.healthCheck.Check(ctx) != nil {
+		return false
+	}
+
+	t.unhealthy.Store(false)
+	return true
+}
+
+// fail marks the tier unhealthy if it has a health check, errors of the
+// caller's context don't tell anything about the tier
+func (t *chainTier) fail(err error) {
+	if t.healthCheck == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return
+	}
+
+	t.nextCheck.Store(time.Now().Add(t.healthCheck.Interval).UnixNano())
+	t.unhealthy.Store(true)
+}
+
 type ChainCache[T any] struct {
-	caches       []Cache[T]
-	singleFlight SingleFlight[string, T]
+	caches        []Cache[T]
+	tiers         []*chainTier
+	asyncBackfill bool
+	singleFlight  SingleFlight[string, T]
 }
 
 // NewChainCache instantiates a new cache that combines other caches
 func NewChainCache[T any](caches ...Cache[T]) *ChainCache[T] {
+	return NewChainCacheWithOptions(caches)
+}
+
+// NewChainCacheWithOptions instantiates a new cache that combines other caches,
+// the options refer to the caches by their index
+func NewChainCacheWithOptions[T any](caches []Cache[T], options ...ChainCacheOption) *ChainCache[T] {
 	if len(caches) == 0 {
 		panic("caches can't be empty")
 	}
 
+	config := &ChainCacheConfig{}
+	for _, option := range options {
+		option(config)
+	}
+
+	tiers := make([]*chainTier, len(caches))
+	for index := range tiers {
+		tiers[index] = &chainTier{}
+	}
+
+	for index, policy := range config.Policies {
+		if index < 0 || index >= len(caches) {
+			panic(fmt.Sprintf("gocache: tier policy index %d out of range", index))
+		}
+		tiers[index].policy = policy
+	}
+
+	for index, healthCheck := range config.HealthChecks {
+		if index < 0 || index >= len(caches) {
+			panic(fmt.Sprintf("gocache: tier health check index %d out of range", index))
+		}
+		tiers[index].healthCheck = &healthCheck
+	}
+
+	for index, skip := range config.SkipBackfill {
+		if index < 0 || index >= len(caches) {
+			panic(fmt.Sprintf("gocache: skip backfill index %d out of range", index))
+		}
+		tiers[index].noBackfill = skip
+	}
+
 	return &ChainCache[T]{
-		caches:       caches,
-		singleFlight: NewSingleFlight[string, T](),
+		caches:        caches,
+		tiers:         tiers,
+		asyncBackfill: config.AsyncBackfill,
+		singleFlight:  NewSingleFlight[string, T](),
 	}
 }
 
 func (c ChainCache[T]) Set(ctx context.Context, key string, value T) error {
-	var err error
+	var errs []error
 	for index := len(c.caches) - 1; index >= 0; index-- {
-		err = c.caches[index].Set(ctx, key, value)
-		if err != nil {
-			return err
+		tier := c.tiers[index]
+		if !tier.available(ctx) {
+			continue
+		}
+
+		err := c.caches[index].Set(ctx, key, value)
+		if err == nil {
+			continue
+		}
+
+		tier.fail(err)
+		switch tier.policy {
+		case FailFast:
+			return joinErrors(append(errs, err))
+		case SkipOnError:
+			errs = append(errs, err)
 		}
 	}
 
-	return nil
+	return joinErrors(errs)
 }
 
-func (c ChainCache[T]) Get(ctx context.Context, key string) (value T, err error) {
+// Get returns the value of the first tier that has it and writes it to the
+// tiers before. The backfilled value expires with the value of the tier it was
+// found in if both tiers implement TTLCache. If no tier has it, the error
+// matches ErrRecordNotFound and also holds the errors of the SkipOnError tiers
+func (c ChainCache[T]) Get(ctx context.Context, key string) (T, error) {
 	return c.singleFlight.DoCtx(ctx, func(ctx context.Context, key string) (T, error) {
-		for index, cache := range c.caches {
-			value, err = cache.Get(ctx, key)
-			if err == ErrRecordNotFound {
+		var value T
+		var ttl time.Duration
+		var err error
+		var errs []error
+		for index := range c.caches {
+			tier := c.tiers[index]
+			if !tier.available(ctx) {
+				continue
+			}
+
+			value, ttl, err = c.getWithTTL(ctx, index, key)
+			if errors.Is(err, ErrRecordNotFound) {
 				continue
 			}
 			if err != nil {
-				return value, err
+				tier.fail(err)
+				switch tier.policy {
+				case FailFast:
+					return value, err
+				case SkipOnError:
+					errs = append(errs, err)
+				}
+				continue
 			}
 
-			// refresh previous caches
-			for i := 0; i < index; i++ {
-				err = c.caches[i].Set(ctx, key, value)
-				if err != nil {
-					continue
-				}
+			if c.asyncBackfill {
+				go c.backfill(context.WithoutCancel(ctx), index, key, value, ttl)
+			} else {
+				c.backfill(ctx, index, key, value, ttl)
 			}
 
 			return value, nil
 		}
 
-		return value, ErrRecordNotFound
+		var zero T
+		if len(errs) > 0 {
+			return zero, errors.Join(append([]error{ErrRecordNotFound}, errs...)...)
+		}
+
+		return zero, ErrRecordNotFound
 	}, key)
 }
 
+// getWithTTL reads the key from the tier at index, along with its remaining
+// lifetime if there are previous tiers to backfill and the tier can tell it
+func (c ChainCache[T]) getWithTTL(ctx context.Context, index int, key string) (T, time.Duration, error) {
+	if cache, ok := c.caches[index].(TTLCache[T]); ok && index > 0 {
+		return cache.GetWithTTL(ctx, key)
+	}
+
+	value, err := c.caches[index].Get(ctx, key)
+	return value, 0, err
+}
+
+// backfill writes the value found in the tier at index to the previous tiers,
+// errors are ignored because the value was found
+func (c ChainCache[T]) backfill(ctx context.Context, index int, key string, value T, ttl time.Duration) {
+	for i := 0; i < index; i++ {
+		tier := c.tiers[i]
+		if tier.noBackfill || !tier.available(ctx) {
+			continue
+		}
+
+		if cache, ok := c.caches[i].(TTLCache[T]); ok && ttl > 0 {
+			cache.SetWithTTL(ctx, key, value, ttl)
+		} else {
+			c.caches[i].Set(ctx, key, value)
+		}
+	}
+}
+
+// Delete deletes the key from every tier, even if some of them fail
 func (c ChainCache[T]) Delete(ctx context.Context, key string) error {
-	var err error
+	var errs []error
 	for index := len(c.caches) - 1; index >= 0; index-- {
-		if e := c.caches[index].Delete(ctx, key); e != nil && err == nil {
-			err = e
+		tier := c.tiers[index]
+		if !tier.available(ctx) {
+			continue
+		}
+
+		err := c.caches[index].Delete(ctx, key)
+		if err == nil {
+			continue
 		}
+
+		tier.fail(err)
+		if tier.policy != BestEffort {
+			errs = append(errs, err)
+		}
+	}
+
+	return joinErrors(errs)
+}
+
+// joinErrors joins the errors, a single error is returned as it is
+func joinErrors(errs []error) error {
+	if len(errs) == 1 {
+		return errs[0]
+	}
+
+	return errors.Join(errs...)
+}
+
+// SetNX sets the value only if the key does not exist in the last cache of the
+// chain, which acts as the source of truth for atomic operations and must
+// implement AtomicCache. On success the value is written to the previous
+// caches, otherwise the key is removed from them so that the next Get reads
+// the current value from the last cache.
+func (c ChainCache[T]) SetNX(ctx context.Context, key string, value T) (bool, error) {
+	last, ok := c.caches[len(c.caches)-1].(AtomicCache[T])
+	if !ok {
+		return false, ErrNotSupported
+	}
+
+	set, err := last.SetNX(ctx, key, value)
+	if err != nil {
+		return false, err
+	}
+
+	if set {
+		c.refreshPrevious(ctx, key, value)
+	} else {
+		c.invalidatePrevious(ctx, key)
+	}
+
+	return set, nil
+}
+
+// GetOrSet returns the value of the key in the last cache of the chain, or
+// sets the given value there if the key does not exist. Either way the
+// resulting value is written to the previous caches.
+func (c ChainCache[T]) GetOrSet(ctx context.Context, key string, value T) (actual T, loaded bool, err error) {
+	last, ok := c.caches[len(c.caches)-1].(AtomicCache[T])
+	if !ok {
+		return actual, false, ErrNotSupported
+	}
+
+	actual, loaded, err = last.GetOrSet(ctx, key, value)
+	if err != nil {
+		return actual, false, err
+	}
+
+	c.refreshPrevious(ctx, key, actual)
+
+	return actual, loaded, nil
+}
+
+// CompareAndSwap compares and swaps the value in the last cache of the chain.
+// The previous caches may hold stale values, so they are updated on success
+// and invalidated on failure.
+func (c ChainCache[T]) CompareAndSwap(ctx context.Context, key string, old, new T) (bool, error) {
+	last, ok := c.caches[len(c.caches)-1].(AtomicCache[T])
+	if !ok {
+		return false, ErrNotSupported
+	}
+
+	swapped, err := last.CompareAndSwap(ctx, key, old, new)
+	if err != nil {
+		return false, err
+	}
+
+	if swapped {
+		c.refreshPrevious(ctx, key, new)
+	} else {
+		c.invalidatePrevious(ctx, key)
+	}
+
+	return swapped, nil
+}
+
+// refreshPrevious writes the value to all available caches before the last
+// one, errors are ignored because the last cache already holds the value
+func (c ChainCache[T]) refreshPrevious(ctx context.Context, key string, value T) {
+	for index := len(c.caches) - 2; index >= 0; index-- {
+		if c.tiers[index].available(ctx) {
+			c.caches[index].Set(ctx, key, value)
+		}
+	}
+}
+
+// invalidatePrevious removes the key from all available caches before the
+// last one
+func (c ChainCache[T]) invalidatePrevious(ctx context.Context, key string) {
+	for index := len(c.caches) - 2; index >= 0; index-- {
+		if c.tiers[index].available(ctx) {
+			c.caches[index].Delete(ctx, key)
+		}
+	}
+}
+
+// GetVersioned reads the value and its version from the last cache of the
+// chain, which must implement VersionedCache. Versions of the previous caches
+// are local to them, so they are refreshed but never consulted.
+func (c ChainCache[T]) GetVersioned(ctx context.Context, key string) (value T, version uint64, err error) {
+	last, ok := c.caches[len(c.caches)-1].(VersionedCache[T])
+	if !ok {
+		return value, 0, ErrNotSupported
+	}
+
+	value, version, err = last.GetVersioned(ctx, key)
+	if err != nil {
+		return value, 0, err
+	}
+
+	c.refreshPrevious(ctx, key, value)
+
+	return value, version, nil
+}
+
+// SetIfVersion sets the value in the last cache of the chain only if its
+// current version equals version. The previous caches are updated on success
+// and invalidated on failure.
+func (c ChainCache[T]) SetIfVersion(ctx context.Context, key string, value T, version uint64) (bool, error) {
+	last, ok := c.caches[len(c.caches)-1].(VersionedCache[T])
+	if !ok {
+		return false, ErrNotSupported
+	}
+
+	set, err := last.SetIfVersion(ctx, key, value, version)
+	if err != nil {
+		return false, err
+	}
+
+	if set {
+		c.refreshPrevious(ctx, key, value)
+	} else {
+		c.invalidatePrevious(ctx, key)
 	}
 
-	return err
+	return set, nil
 }
@@ -2,6 +2,8 @@ package gocache
 
 import (
 	"context"
+	"errors"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -103,6 +105,388 @@ func TestChainCache_Delete(t *testing.T) {
 	}
 }
 
+func TestChainCache_CompareAndSwap(t *testing.T) {
+	ctx := context.Background()
+	ms1 := NewMemoryCache[string](time.Minute)
+	ms2 := NewMemoryCache[string](time.Minute)
+	cc := NewChainCache[string](ms1, ms2)
+
+	set, err := cc.SetNX(ctx, "k1", "v1")
+	if err != nil || !set {
+		t.Errorf("ChainCache.SetNX() got = %v, error = %v, want = %v", set, err, true)
+	}
+
+	// another writer updates the last level behind the first level's back
+	if err := ms2.Set(ctx, "k1", "v2"); err != nil {
+		t.Errorf("MemoryCache.Set() error = %v", err)
+	}
+
+	// the comparison is made against the last level, and the stale first
+	// level is invalidated when it fails
+	swapped, err := cc.CompareAndSwap(ctx, "k1", "v1", "v3")
+	if err != nil || swapped {
+		t.Errorf("ChainCache.CompareAndSwap() got = %v, error = %v, want = %v", swapped, err, false)
+	}
+	if _, err := ms1.Get(ctx, "k1"); err != ErrRecordNotFound {
+		t.Errorf("MemoryCache.Get() after failed swap error = %v, want = %v", err, ErrRecordNotFound)
+	}
+
+	swapped, err = cc.CompareAndSwap(ctx, "k1", "v2", "v3")
+	if err != nil || !swapped {
+		t.Errorf("ChainCache.CompareAndSwap() got = %v, error = %v, want = %v", swapped, err, true)
+	}
+	if got, _ := ms1.Get(ctx, "k1"); got != "v3" {
+		t.Errorf("MemoryCache.Get() after swap got = %v, want = %v", got, "v3")
+	}
+
+	actual, loaded, err := cc.GetOrSet(ctx, "k1", "v4")
+	if err != nil || !loaded || actual != "v3" {
+		t.Errorf("ChainCache.GetOrSet() got = %v, loaded = %v, error = %v", actual, loaded, err)
+	}
+}
+
+func TestChainCache_SetIfVersion(t *testing.T) {
+	ctx := context.Background()
+	ms1 := NewMemoryCache[string](time.Minute)
+	ms2 := NewMemoryCache[string](time.Minute)
+	cc := NewChainCache[string](ms1, ms2)
+
+	if err := cc.Set(ctx, "k1", "v1"); err != nil {
+		t.Errorf("ChainCache.Set() error = %v", err)
+	}
+
+	_, version, err := cc.GetVersioned(ctx, "k1")
+	if err != nil {
+		t.Errorf("ChainCache.GetVersioned() error = %v", err)
+	}
+
+	set, err := cc.SetIfVersion(ctx, "k1", "v2", version)
+	if err != nil || !set {
+		t.Errorf("ChainCache.SetIfVersion() got = %v, error = %v, want = %v", set, err, true)
+	}
+	if got, _ := ms1.Get(ctx, "k1"); got != "v2" {
+		t.Errorf("MemoryCache.Get() after SetIfVersion got = %v, want = %v", got, "v2")
+	}
+
+	// the version is stale now
+	set, err = cc.SetIfVersion(ctx, "k1", "v3", version)
+	if err != nil || set {
+		t.Errorf("ChainCache.SetIfVersion() got = %v, error = %v, want = %v", set, err, false)
+	}
+}
+
+func TestChainCache_AtomicNotSupported(t *testing.T) {
+	ctx := context.Background()
+	// hide the atomic methods of the last level behind the plain interface
+	var last Cache[string] = NewMemoryCache[string](time.Minute)
+	cc := NewChainCache[string](NewMemoryCache[string](time.Minute), struct{ Cache[string] }{last})
+
+	if _, err := cc.SetNX(ctx, "k1", "v1"); err != ErrNotSupported {
+		t.Errorf("ChainCache.SetNX() error = %v, want = %v", err, ErrNotSupported)
+	}
+}
+
+// flakyCache fails every call with ErrBackendUnavailable while down is set
+type flakyCache[T any] struct {
+	Cache[T]
+	down  atomic.Bool
+	calls atomic.Int32
+}
+
+func (c *flakyCache[T]) Set(ctx context.Context, key string, value T) error {
+	c.calls.Add(1)
+	if c.down.Load() {
+		return ErrBackendUnavailable
+	}
+	return c.Cache.Set(ctx, key, value)
+}
+
+func (c *flakyCache[T]) Get(ctx context.Context, key string) (T, error) {
+	c.calls.Add(1)
+	if c.down.Load() {
+		var zero T
+		return zero, ErrBackendUnavailable
+	}
+	return c.Cache.Get(ctx, key)
+}
+
+func (c *flakyCache[T]) Delete(ctx context.Context, key string) error {
+	c.calls.Add(1)
+	if c.down.Load() {
+		return ErrBackendUnavailable
+	}
+	return c.Cache.Delete(ctx, key)
+}
+
+func TestChainCache_FailurePolicies(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   TierFailurePolicy
+		getErr   error
+		setErr   error
+		found    bool
+		notFound bool
+	}{{
+		name:   "fail fast",
+		policy: FailFast,
+		getErr: ErrBackendUnavailable,
+		setErr: ErrBackendUnavailable,
+	}, {
+		name:     "skip on error",
+		policy:   SkipOnError,
+		getErr:   ErrBackendUnavailable,
+		setErr:   ErrBackendUnavailable,
+		found:    true,
+		notFound: true,
+	}, {
+		name:     "best effort",
+		policy:   BestEffort,
+		found:    true,
+		notFound: true,
+	}}
+
+	ctx := context.Background()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			first := &flakyCache[string]{Cache: NewMemoryCache[string](time.Minute)}
+			last := NewMemoryCache[string](time.Minute)
+			cc := NewChainCacheWithOptions[string]([]Cache[string]{first, last}, WithTierPolicy(0, tt.policy))
+
+			last.Set(ctx, "k1", "v1")
+			first.down.Store(true)
+
+			got, err := cc.Get(ctx, "k1")
+			if tt.found && (err != nil || got != "v1") {
+				t.Errorf("ChainCache.Get() got = %v, error = %v, want = %v", got, err, "v1")
+			}
+			if !tt.found && !errors.Is(err, tt.getErr) {
+				t.Errorf("ChainCache.Get() error got = %v, want = %v", err, tt.getErr)
+			}
+
+			_, err = cc.Get(ctx, "k2")
+			if tt.notFound && !errors.Is(err, ErrRecordNotFound) {
+				t.Errorf("ChainCache.Get() error got = %v, want = %v", err, ErrRecordNotFound)
+			}
+			if errors.Is(err, ErrBackendUnavailable) != (tt.policy != BestEffort) {
+				t.Errorf("ChainCache.Get() error got = %v, want backend error = %v", err, tt.policy != BestEffort)
+			}
+
+			// the last tier is written even if the first one fails
+			err = cc.Set(ctx, "k3", "v3")
+			if !errors.Is(err, tt.setErr) || (tt.setErr == nil && err != nil) {
+				t.Errorf("ChainCache.Set() error got = %v, want = %v", err, tt.setErr)
+			}
+			if got, _ := last.Get(ctx, "k3"); got != "v3" {
+				t.Errorf("MemoryCache.Get() got = %v, want = %v", got, "v3")
+			}
+		})
+	}
+}
+
+func TestChainCache_JoinedErrors(t *testing.T) {
+	ctx := context.Background()
+	first := &flakyCache[string]{Cache: NewMemoryCache[string](time.Minute)}
+	second := &flakyCache[string]{Cache: NewMemoryCache[string](time.Minute)}
+	cc := NewChainCacheWithOptions[string]([]Cache[string]{first, second},
+		WithTierPolicy(0, SkipOnError), WithTierPolicy(1, SkipOnError))
+
+	first.down.Store(true)
+	second.down.Store(true)
+
+	err := cc.Set(ctx, "k1", "v1")
+	if joined, ok := err.(interface{ Unwrap() []error }); !ok || len(joined.Unwrap()) != 2 {
+		t.Errorf("ChainCache.Set() error got = %v, want 2 joined errors", err)
+	}
+
+	err = cc.Delete(ctx, "k1")
+	if joined, ok := err.(interface{ Unwrap() []error }); !ok || len(joined.Unwrap()) != 2 {
+		t.Errorf("ChainCache.Delete() error got = %v, want 2 joined errors", err)
+	}
+}
+
+func TestChainCache_HealthCheck(t *testing.T) {
+	ctx := context.Background()
+	first := &flakyCache[string]{Cache: NewMemoryCache[string](time.Minute)}
+	last := NewMemoryCache[string](time.Minute)
+
+	var checks atomic.Int32
+	check := func(ctx context.Context) error {
+		checks.Add(1)
+		if first.down.Load() {
+			return ErrBackendUnavailable
+		}
+		return nil
+	}
+
+	interval := 100 * time.Millisecond
+	cc := NewChainCacheWithOptions[string]([]Cache[string]{first, last},
+		WithTierPolicy(0, BestEffort), WithTierHealthCheck(0, check, interval))
+
+	last.Set(ctx, "k1", "v1")
+	first.down.Store(true)
+
+	// the first failure marks the tier unhealthy, it is bypassed afterwards
+	for i := 0; i < 5; i++ {
+		if got, err := cc.Get(ctx, "k1"); err != nil || got != "v1" {
+			t.Errorf("ChainCache.Get() got = %v, error = %v, want = %v", got, err, "v1")
+		}
+	}
+	if calls := first.calls.Load(); calls != 1 {
+		t.Errorf("flakyCache calls got = %v, want = %v", calls, 1)
+	}
+	if got := checks.Load(); got != 0 {
+		t.Errorf("health checks got = %v, want = %v", got, 0)
+	}
+
+	// the check fails, the tier stays bypassed for another interval
+	time.Sleep(interval + 20*time.Millisecond)
+	cc.Get(ctx, "k1")
+	cc.Get(ctx, "k1")
+	if got := checks.Load(); got != 1 {
+		t.Errorf("health checks got = %v, want = %v", got, 1)
+	}
+	if calls := first.calls.Load(); calls != 1 {
+		t.Errorf("flakyCache calls got = %v, want = %v", calls, 1)
+	}
+
+	// the check succeeds, the tier is used and refreshed again
+	first.down.Store(false)
+	time.Sleep(interval + 20*time.Millisecond)
+	if got, err := cc.Get(ctx, "k1"); err != nil || got != "v1" {
+		t.Errorf("ChainCache.Get() got = %v, error = %v, want = %v", got, err, "v1")
+	}
+	if got, err := first.Cache.Get(ctx, "k1"); err != nil || got != "v1" {
+		t.Errorf("MemoryCache.Get() got = %v, error = %v, want = %v", got, err, "v1")
+	}
+}
+
+func TestNewChainCacheWithOptions_InvalidIndex(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("NewChainCacheWithOptions() did not panic")
+		}
+	}()
+
+	NewChainCacheWithOptions[string]([]Cache[string]{NewMemoryCache[string](time.Minute)}, WithTierPolicy(1, SkipOnError))
+}
+
+// notifyingCache signals every Set on a channel
+type notifyingCache[T any] struct {
+	Cache[T]
+	sets chan string
+}
+
+func (c notifyingCache[T]) Set(ctx context.Context, key string, value T) error {
+	err := c.Cache.Set(ctx, key, value)
+	c.sets <- key
+	return err
+}
+
+func TestChainCache_BackfillTTL(t *testing.T) {
+	ctx := context.Background()
+	ms := NewMemoryCache[string](time.Minute)
+	ds, err := NewDiskCache[string](t.TempDir(), time.Hour, DiskCacheOptions{})
+	if err != nil {
+		t.Fatalf("NewDiskCache() error = %v", err)
+	}
+	defer ds.Close()
+
+	cc := NewChainCache[string](ms, ds)
+
+	tests := []struct {
+		name    string
+		key     string
+		ttl     time.Duration
+		wantMin time.Duration
+		wantMax time.Duration
+	}{{
+		name:    "remaining ttl of the source",
+		key:     "k1",
+		ttl:     10 * time.Second,
+		wantMin: 9 * time.Second,
+		wantMax: 10 * time.Second,
+	}, {
+		name:    "capped by the expiration of the tier",
+		key:     "k2",
+		ttl:     30 * time.Minute,
+		wantMin: time.Minute * 95 / 100,
+		wantMax: time.Minute * 105 / 100,
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ds.SetWithTTL(ctx, tt.key, "v1", tt.ttl); err != nil {
+				t.Errorf("DiskCache.SetWithTTL() error = %v", err)
+			}
+
+			if got, err := cc.Get(ctx, tt.key); err != nil || got != "v1" {
+				t.Errorf("ChainCache.Get() got = %v, error = %v, want = %v", got, err, "v1")
+			}
+
+			_, ttl, err := ms.GetWithTTL(ctx, tt.key)
+			if err != nil || ttl < tt.wantMin || ttl > tt.wantMax {
+				t.Errorf("MemoryCache.GetWithTTL() ttl got = %v, error = %v, want in [%v, %v]", ttl, err, tt.wantMin, tt.wantMax)
+			}
+		})
+	}
+}
+
+func TestChainCache_AsyncBackfill(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	first := notifyingCache[string]{Cache: NewMemoryCache[string](time.Minute), sets: make(chan string, 1)}
+	last := NewMemoryCache[string](time.Minute)
+	cc := NewChainCacheWithOptions[string]([]Cache[string]{first, last}, WithAsyncBackfill())
+
+	last.Set(ctx, "k1", "v1")
+	if got, err := cc.Get(ctx, "k1"); err != nil || got != "v1" {
+		t.Errorf("ChainCache.Get() got = %v, error = %v, want = %v", got, err, "v1")
+	}
+
+	// the backfill outlives the request
+	cancel()
+
+	select {
+	case key := <-first.sets:
+		if key != "k1" {
+			t.Errorf("ChainCache backfill key got = %v, want = %v", key, "k1")
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("ChainCache did not backfill the first tier")
+	}
+
+	if got, err := first.Get(context.Background(), "k1"); err != nil || got != "v1" {
+		t.Errorf("MemoryCache.Get() got = %v, error = %v, want = %v", got, err, "v1")
+	}
+}
+
+func TestChainCache_WithoutBackfill(t *testing.T) {
+	ctx := context.Background()
+	ms1 := NewMemoryCache[string](time.Minute)
+	ms2 := NewMemoryCache[string](time.Minute)
+	ms3 := NewMemoryCache[string](time.Minute)
+	cc := NewChainCacheWithOptions[string]([]Cache[string]{ms1, ms2, ms3}, WithoutBackfill(0))
+
+	ms3.Set(ctx, "k1", "v1")
+	if got, err := cc.Get(ctx, "k1"); err != nil || got != "v1" {
+		t.Errorf("ChainCache.Get() got = %v, error = %v, want = %v", got, err, "v1")
+	}
+
+	if _, err := ms1.Get(ctx, "k1"); err != ErrRecordNotFound {
+		t.Errorf("MemoryCache.Get() error got = %v, want = %v", err, ErrRecordNotFound)
+	}
+	if got, err := ms2.Get(ctx, "k1"); err != nil || got != "v1" {
+		t.Errorf("MemoryCache.Get() got = %v, error = %v, want = %v", got, err, "v1")
+	}
+
+	// writes still reach the tier
+	if err := cc.Set(ctx, "k2", "v2"); err != nil {
+		t.Errorf("ChainCache.Set() error = %v", err)
+	}
+	if got, err := ms1.Get(ctx, "k2"); err != nil || got != "v2" {
+		t.Errorf("MemoryCache.Get() got = %v, error = %v, want = %v", got, err, "v2")
+	}
+}
+
 func BenchmarkChainCache_GetString(b *testing.B) {
 	ctx := context.Background()
 	client := redis.NewClient(&redis.Options{
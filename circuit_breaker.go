@@ -0,0 +1,246 @@
+package gocache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+type CircuitState int
+
+const (
+	// calls pass through, failures are counted
+	CircuitClosed CircuitState = iota
+	// calls are rejected with ErrCircuitOpen
+	CircuitOpen
+	// a few probe calls pass through to test whether the backend recovered
+	CircuitHalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+type CircuitBreakerConfig struct {
+	// failure rate in (0, 1] that opens the circuit
+	FailureRateThreshold float64
+	// minimum number of calls in a window before the failure rate is checked
+	MinimumRequests int
+	// length of the window over which calls are counted in closed state
+	Window time.Duration
+	// how long the circuit stays open before letting probe calls through
+	OpenTimeout time.Duration
+	// number of probe calls in half-open state, they must all succeed to
+	// close the circuit
+	HalfOpenRequests int
+	// called after each state change, e.g. to export metrics. It runs with
+	// the breaker locked and must not call back into it
+	OnStateChange func(from, to CircuitState)
+}
+
+type CircuitBreakerOption func(*CircuitBreakerConfig)
+
+// WithFailureRate opens the circuit when at least rate of the calls in a
+// window failed and there were at least minimumRequests of them, default 0.5
+// of 10 requests
+func WithFailureRate(rate float64, minimumRequests int) CircuitBreakerOption {
+	return func(c *CircuitBreakerConfig) {
+		c.FailureRateThreshold = rate
+		c.MinimumRequests = minimumRequests
+	}
+}
+
+// WithFailureWindow sets the window over which calls are counted, default 10s
+func WithFailureWindow(window time.Duration) CircuitBreakerOption {
+	return func(c *CircuitBreakerConfig) {
+		c.Window = window
+	}
+}
+
+// WithOpenTimeout sets how long the circuit stays open, default 5s
+func WithOpenTimeout(timeout time.Duration) CircuitBreakerOption {
+	return func(c *CircuitBreakerConfig) {
+		c.OpenTimeout = timeout
+	}
+}
+
+// WithHalfOpenRequests sets the number of probe calls, default 1
+func WithHalfOpenRequests(requests int) CircuitBreakerOption {
+	return func(c *CircuitBreakerConfig) {
+		c.HalfOpenRequests = requests
+	}
+}
+
+// WithStateChangeHook sets a function called after each state change
+func WithStateChangeHook(fn func(from, to CircuitState)) CircuitBreakerOption {
+	return func(c *CircuitBreakerConfig) {
+		c.OnStateChange = fn
+	}
+}
+
+// CircuitBreakerCounts holds the calls counted in the current state
+type CircuitBreakerCounts struct {
+	Requests int
+	Failures int
+}
+
+// CircuitBreaker stops calling a failing backend for a while, so that an
+// outage is not amplified by retries of every caller
+type CircuitBreaker struct {
+	config *CircuitBreakerConfig
+	lock   sync.Mutex
+	state  CircuitState
+	// increases on every state change, so that a call started in a previous
+	// state is not counted in the current one
+	generation uint64
+	counts     CircuitBreakerCounts
+	// start of the counting window in closed state, or the time the circuit
+	// opened in open state
+	since time.Time
+	// probe calls started in half-open state
+	probes int
+}
+
+func NewCircuitBreaker(options ...CircuitBreakerOption) *CircuitBreaker {
+	config := &CircuitBreakerConfig{
+		FailureRateThreshold: 0.5,
+		MinimumRequests:      10,
+		Window:               10 * time.Second,
+		OpenTimeout:          5 * time.Second,
+		HalfOpenRequests:     1,
+	}
+
+	for _, option := range options {
+		option(config)
+	}
+
+	return &CircuitBreaker{
+		config: config,
+		since:  time.Now(),
+	}
+}
+
+// State returns the current state of the circuit
+func (b *CircuitBreaker) State() CircuitState {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.refresh(time.Now())
+	return b.state
+}
+
+// Counts returns the calls counted in the current state
+func (b *CircuitBreaker) Counts() CircuitBreakerCounts {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.refresh(time.Now())
+	return b.counts
+}
+
+// Allow returns ErrCircuitOpen if the call must not be made, otherwise it
+// returns a function that must be called with the result of the call.
+// context.Canceled and context.DeadlineExceeded results are not counted.
+func (b *CircuitBreaker) Allow() (done func(err error), err error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.refresh(time.Now())
+
+	switch b.state {
+	case CircuitOpen:
+		return nil, ErrCircuitOpen
+	case CircuitHalfOpen:
+		if b.probes >= b.config.HalfOpenRequests {
+			return nil, ErrCircuitOpen
+		}
+		b.probes++
+	}
+
+	generation := b.generation
+	return func(err error) {
+		b.record(generation, err)
+	}, nil
+}
+
+// record counts the result of a call started in the given generation
+func (b *CircuitBreaker) record(generation uint64, err error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	now := time.Now()
+	b.refresh(now)
+	if generation != b.generation {
+		return
+	}
+
+	// the caller gave up or ran out of time, this says nothing about the
+	// backend. The call is not counted and its probe slot is given back to
+	// another call. ErrLoadTimeout is the backend's fault and is counted
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		if b.state == CircuitHalfOpen {
+			b.probes--
+		}
+		return
+	}
+
+	b.counts.Requests++
+	if err != nil {
+		b.counts.Failures++
+	}
+
+	switch b.state {
+	case CircuitClosed:
+		if b.counts.Requests >= b.config.MinimumRequests &&
+			float64(b.counts.Failures) >= b.config.FailureRateThreshold*float64(b.counts.Requests) {
+			b.setState(CircuitOpen, now)
+		}
+	case CircuitHalfOpen:
+		if err != nil {
+			b.setState(CircuitOpen, now)
+		} else if b.counts.Requests >= b.config.HalfOpenRequests {
+			b.setState(CircuitClosed, now)
+		}
+	}
+}
+
+// refresh moves to the next state or window when its time has come
+func (b *CircuitBreaker) refresh(now time.Time) {
+	switch b.state {
+	case CircuitClosed:
+		if now.Sub(b.since) >= b.config.Window {
+			b.since = now
+			b.counts = CircuitBreakerCounts{}
+		}
+	case CircuitOpen:
+		if now.Sub(b.since) >= b.config.OpenTimeout {
+			b.setState(CircuitHalfOpen, now)
+		}
+	}
+}
+
+func (b *CircuitBreaker) setState(state CircuitState, now time.Time) {
+	from := b.state
+
+	b.state = state
+	b.generation++
+	b.counts = CircuitBreakerCounts{}
+	b.since = now
+	b.probes = 0
+
+	if b.config.OnStateChange != nil {
+		b.config.OnStateChange(from, state)
+	}
+}
@@ -0,0 +1,217 @@
+package gocache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreaker_States(t *testing.T) {
+	var transitions []CircuitState
+	b := NewCircuitBreaker(
+		WithFailureRate(0.5, 4),
+		WithOpenTimeout(100*time.Millisecond),
+		WithHalfOpenRequests(2),
+		WithStateChangeHook(func(from, to CircuitState) {
+			transitions = append(transitions, to)
+		}),
+	)
+
+	errBackend := errors.New("backend unavailable")
+	call := func(err error) error {
+		done, allowErr := b.Allow()
+		if allowErr != nil {
+			return allowErr
+		}
+		done(err)
+		return nil
+	}
+
+	// below the minimum number of requests the circuit stays closed
+	for _, err := range []error{errBackend, errBackend, nil} {
+		if e := call(err); e != nil {
+			t.Errorf("CircuitBreaker.Allow() error = %v", e)
+		}
+	}
+	if state := b.State(); state != CircuitClosed {
+		t.Errorf("CircuitBreaker.State() = %v, want = %v", state, CircuitClosed)
+	}
+
+	// the 4th request reaches the threshold with 3 failures out of 4
+	call(errBackend)
+	if state := b.State(); state != CircuitOpen {
+		t.Errorf("CircuitBreaker.State() = %v, want = %v", state, CircuitOpen)
+	}
+	if err := call(nil); err != ErrCircuitOpen {
+		t.Errorf("CircuitBreaker.Allow() error = %v, want = %v", err, ErrCircuitOpen)
+	}
+
+	time.Sleep(150 * time.Millisecond)
+
+	// only the configured number of probes pass in half-open state
+	if state := b.State(); state != CircuitHalfOpen {
+		t.Errorf("CircuitBreaker.State() = %v, want = %v", state, CircuitHalfOpen)
+	}
+	done1, err1 := b.Allow()
+	done2, err2 := b.Allow()
+	_, err3 := b.Allow()
+	if err1 != nil || err2 != nil || err3 != ErrCircuitOpen {
+		t.Errorf("CircuitBreaker.Allow() in half-open errors = %v, %v, %v", err1, err2, err3)
+	}
+
+	done1(nil)
+	done2(nil)
+	if state := b.State(); state != CircuitClosed {
+		t.Errorf("CircuitBreaker.State() = %v, want = %v", state, CircuitClosed)
+	}
+
+	want := []CircuitState{CircuitOpen, CircuitHalfOpen, CircuitClosed}
+	if len(transitions) != len(want) {
+		t.Fatalf("state transitions = %v, want = %v", transitions, want)
+	}
+	for index := range want {
+		if transitions[index] != want[index] {
+			t.Errorf("state transitions = %v, want = %v", transitions, want)
+		}
+	}
+}
+
+func TestCircuitBreaker_HalfOpenFailure(t *testing.T) {
+	b := NewCircuitBreaker(WithFailureRate(1, 1), WithOpenTimeout(50*time.Millisecond))
+
+	done, _ := b.Allow()
+	done(errors.New("backend unavailable"))
+
+	time.Sleep(100 * time.Millisecond)
+
+	// a failed probe opens the circuit again
+	done, err := b.Allow()
+	if err != nil {
+		t.Fatalf("CircuitBreaker.Allow() error = %v", err)
+	}
+	done(errors.New("backend unavailable"))
+
+	if state := b.State(); state != CircuitOpen {
+		t.Errorf("CircuitBreaker.State() = %v, want = %v", state, CircuitOpen)
+	}
+
+	// cancelled probes are neither failures nor successes, they give their
+	// slot back to the next probe
+	time.Sleep(100 * time.Millisecond)
+	done, _ = b.Allow()
+	done(context.Canceled)
+
+	if state := b.State(); state != CircuitHalfOpen {
+		t.Errorf("CircuitBreaker.State() = %v, want = %v", state, CircuitHalfOpen)
+	}
+	if counts := b.Counts(); counts.Requests != 0 {
+		t.Errorf("CircuitBreaker.Counts() requests = %v, want = %v", counts.Requests, 0)
+	}
+
+	done, err = b.Allow()
+	if err != nil {
+		t.Fatalf("CircuitBreaker.Allow() error = %v", err)
+	}
+	done(nil)
+
+	if state := b.State(); state != CircuitClosed {
+		t.Errorf("CircuitBreaker.State() = %v, want = %v", state, CircuitClosed)
+	}
+}
+
+func TestCircuitBreaker_Deadline(t *testing.T) {
+	b := NewCircuitBreaker(WithFailureRate(1, 1), WithOpenTimeout(time.Minute))
+
+	// the caller's deadline says nothing about the backend
+	done, _ := b.Allow()
+	done(fmt.Errorf("query: %w", context.DeadlineExceeded))
+
+	if state := b.State(); state != CircuitClosed {
+		t.Errorf("CircuitBreaker.State() = %v, want = %v", state, CircuitClosed)
+	}
+	if counts := b.Counts(); counts.Requests != 0 {
+		t.Errorf("CircuitBreaker.Counts() requests = %v, want = %v", counts.Requests, 0)
+	}
+
+	// the load timeout does
+	done, _ = b.Allow()
+	done(ErrLoadTimeout)
+
+	if state := b.State(); state != CircuitOpen {
+		t.Errorf("CircuitBreaker.State() = %v, want = %v", state, CircuitOpen)
+	}
+}
+
+func TestLoadableCache_CircuitBreaker_Deadline(t *testing.T) {
+	b := NewCircuitBreaker(WithFailureRate(1, 1), WithOpenTimeout(time.Minute))
+	lc := NewLoadableCache[string, string](NewMemoryCache[string](time.Minute),
+		WithCircuitBreaker(b), WithLoadTimeout(20*time.Millisecond))
+
+	// a load function returning as soon as its context is done
+	fn := func(ctx context.Context, arg string) (string, error) {
+		<-ctx.Done()
+		return "", ctx.Err()
+	}
+
+	// the caller's deadline is not counted
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+	defer cancel()
+	if _, err := lc.LoadCtx(ctx, fn, "k1"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("LoadableCache.LoadCtx() error = %v, want = %v", err, context.DeadlineExceeded)
+	}
+	if state := b.State(); state != CircuitClosed {
+		t.Errorf("CircuitBreaker.State() = %v, want = %v", state, CircuitClosed)
+	}
+
+	// the load timeout is
+	if _, err := lc.LoadCtx(context.Background(), fn, "k2"); err != ErrLoadTimeout {
+		t.Errorf("LoadableCache.LoadCtx() error = %v, want = %v", err, ErrLoadTimeout)
+	}
+	if state := b.State(); state != CircuitOpen {
+		t.Errorf("CircuitBreaker.State() = %v, want = %v", state, CircuitOpen)
+	}
+}
+
+func TestLoadableCache_CircuitBreaker(t *testing.T) {
+	ctx := context.Background()
+	b := NewCircuitBreaker(WithFailureRate(0.5, 2), WithOpenTimeout(time.Minute))
+	ms := NewMemoryCache[string](time.Minute)
+	lc := NewLoadableCache[string, string](ms, WithCircuitBreaker(b), WithStaleTTL(time.Hour))
+
+	var calls int
+	fn := func(ctx context.Context, arg string) (string, error) {
+		calls++
+		if arg == "down" {
+			return "", errors.New("backend unavailable")
+		}
+		return "v1", nil
+	}
+
+	if _, err := lc.LoadCtx(ctx, fn, "k1"); err != nil {
+		t.Errorf("LoadableCache.LoadCtx() error = %v", err)
+	}
+
+	// the value expires from the cache but is kept as a stale value
+	ms.Delete(ctx, "k1")
+
+	if _, err := lc.LoadCtx(ctx, fn, "down"); err == nil {
+		t.Errorf("LoadableCache.LoadCtx() error = nil, want non-nil")
+	}
+
+	// the circuit is open, the stale value is served without calling the backend
+	got, err := lc.LoadCtx(ctx, fn, "k1")
+	if err != nil || got != "v1" {
+		t.Errorf("LoadableCache.LoadCtx() got = %v, error = %v, want = %v", got, err, "v1")
+	}
+
+	// without a stale value the caller gets ErrCircuitOpen
+	if _, err := lc.LoadCtx(ctx, fn, "k2"); err != ErrCircuitOpen {
+		t.Errorf("LoadableCache.LoadCtx() error = %v, want = %v", err, ErrCircuitOpen)
+	}
+
+	if calls != 2 {
+		t.Errorf("load function calls = %v, want = %v", calls, 2)
+	}
+}
@@ -0,0 +1,23 @@
+package gocache
+
+import (
+	"encoding/json"
+)
+
+// Codec encodes cache values into bytes for the caches that store them
+// outside of the process, such as RedisCache
+type Codec interface {
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+}
+
+// JSONCodec is the default codec, it encodes values with encoding/json
+type JSONCodec struct{}
+
+func (JSONCodec) Marshal(v any) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (JSONCodec) Unmarshal(data []byte, v any) error {
+	return json.Unmarshal(data, v)
+}
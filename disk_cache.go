@@ -0,0 +1,512 @@
+package gocache
+
+import (
+	"cmp"
+	"context"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+	diskCacheFileName = "cache.log"
+
+	// crc32, flags, expireAt, key length, value length
+	diskRecordHeaderSize = 4 + 1 + 8 + 4 + 4
+
+	diskRecordPut    byte = 0
+	diskRecordDelete byte = 1
+)
+
+// ErrValueTooLarge is returned by DiskCache.Set for a value that doesn't fit in
+// DiskCacheOptions.MaxSize on its own
+var ErrValueTooLarge = errors.New("value too large")
+
+type DiskCacheOptions struct {
+	// maximum size in bytes of the live records, the records closest to their
+	// expiration are evicted beyond it, 0 means no limit. Setting a value whose
+	// record alone is larger fails with ErrValueTooLarge and keeps the previous
+	// value of the key.
+	MaxSize int64
+	// interval of the background compaction, default 1 minute
+	CompactInterval time.Duration
+	// sync the file after every write, so that no acknowledged write is lost
+	// if the machine crashes
+	SyncWrites bool
+}
+
+// diskEntry locates the live record of a key in the log
+type diskEntry struct {
+	// offset of the record
+	offset int64
+	// size of the whole record
+	size int64
+	// expiration time in unix milliseconds
+	expireAt int64
+}
+
+// DiskCache stores encoded values in an append-only log file and keeps an
+// index of the live records in memory. Every write appends a record with a
+// checksum, so a record torn by a crash is detected and dropped when the log is
+// opened again. Expired, replaced and deleted records are removed by the
+// background compaction, which rewrites the log into a new file and renames it
+// over the old one.
+type DiskCache[T any] struct {
+	config          *CacheConfig
+	options         DiskCacheOptions
+	path            string
+	expiration      time.Duration
+	expiryDeviation float64
+
+	lock  sync.RWMutex
+	file  *os.File
+	index map[string]diskEntry
+	// size of the log file
+	size int64
+	// size of the live records
+	liveSize int64
+
+	stop      chan struct{}
+	stopped   chan struct{}
+	closeOnce sync.Once
+}
+
+// NewDiskCache opens the cache stored in dir, creating it if needed
+func NewDiskCache[T any](dir string, expiration time.Duration, opts DiskCacheOptions, options ...CacheOption) (*DiskCache[T], error) {
+	if expiration <= 0 {
+		panic("gocache: NewDiskCache expiration must be positive")
+	}
+
+	if opts.CompactInterval <= 0 {
+		opts.CompactInterval = time.Minute
+	}
+
+	err := os.MkdirAll(dir, 0o755)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &DiskCache[T]{
+		config:          &CacheConfig{},
+		options:         opts,
+		path:            filepath.Join(dir, diskCacheFileName),
+		expiration:      expiration,
+		expiryDeviation: ExpiryDeviation,
+		stop:            make(chan struct{}),
+		stopped:         make(chan struct{}),
+	}
+
+	for _, option := range options {
+		option(s.config)
+	}
+
+	err = s.open()
+	if err != nil {
+		return nil, err
+	}
+
+	go s.run()
+
+	return s, nil
+}
+
+func (s *DiskCache[T]) Set(ctx context.Context, key string, value T) error {
+	return s.SetWithTTL(ctx, key, value, 0)
+}
+
+// SetWithTTL sets the value to expire after ttl, capped by the expiration of
+// the cache
+func (s *DiskCache[T]) SetWithTTL(ctx context.Context, key string, value T, ttl time.Duration) error {
+	marshaled, err := s.config.codec().Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	key = s.config.key(key)
+
+	// such a record would be evicted by this very Set
+	if s.options.MaxSize > 0 && int64(diskRecordHeaderSize+len(key)+len(marshaled)) > s.options.MaxSize {
+		return ErrValueTooLarge
+	}
+
+	// interval [0.95, 1.05)
+	deviation := 1.0 - s.expiryDeviation + rand.Float64()*s.expiryDeviation*2
+	expiration := time.Duration(float64(s.expiration) * deviation)
+	if ttl > 0 && ttl < s.expiration {
+		expiration = ttl
+	}
+	expireAt := time.Now().Add(expiration).UnixMilli()
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.file == nil {
+		return ErrClosed
+	}
+
+	offset, size, err := s.append(diskRecordPut, expireAt, key, marshaled)
+	if err != nil {
+		return backendError(err)
+	}
+
+	if old, found := s.index[key]; found {
+		s.liveSize -= old.size
+	}
+	s.index[key] = diskEntry{offset: offset, size: size, expireAt: expireAt}
+	s.liveSize += size
+
+	return backendError(s.evict())
+}
+
+func (s *DiskCache[T]) Get(ctx context.Context, key string) (T, error) {
+	value, _, err := s.GetWithTTL(ctx, key)
+	return value, err
+}
+
+// GetWithTTL returns the value of the key along with its remaining lifetime
+func (s *DiskCache[T]) GetWithTTL(ctx context.Context, key string) (value T, ttl time.Duration, err error) {
+	key = s.config.key(key)
+
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	if s.file == nil {
+		return value, 0, ErrClosed
+	}
+
+	now := time.Now().UnixMilli()
+	e, found := s.index[key]
+	if !found || e.expireAt <= now {
+		return value, 0, ErrRecordNotFound
+	}
+
+	record := make([]byte, e.size)
+	_, err = s.file.ReadAt(record, e.offset)
+	if err != nil {
+		return value, 0, backendError(err)
+	}
+
+	keyLength := binary.BigEndian.Uint32(record[13:17])
+	err = s.config.codec().Unmarshal(record[diskRecordHeaderSize+int(keyLength):], &value)
+	if err != nil {
+		return value, 0, err
+	}
+
+	return value, time.Duration(e.expireAt-now) * time.Millisecond, nil
+}
+
+func (s *DiskCache[T]) Delete(ctx context.Context, key string) error {
+	key = s.config.key(key)
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.file == nil {
+		return ErrClosed
+	}
+
+	return backendError(s.remove(key))
+}
+
+// Compact drops the expired entries and rewrites the log if it holds more
+// dead records than live ones
+func (s *DiskCache[T]) Compact() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.file == nil {
+		return ErrClosed
+	}
+
+	now := time.Now().UnixMilli()
+	for key, e := range s.index {
+		if e.expireAt <= now {
+			delete(s.index, key)
+			s.liveSize -= e.size
+		}
+	}
+
+	if s.size-s.liveSize <= s.liveSize {
+		return nil
+	}
+
+	return s.rewrite()
+}
+
+// Close stops the background compaction and closes the log file
+func (s *DiskCache[T]) Close() error {
+	var err error
+	s.closeOnce.Do(func() {
+		close(s.stop)
+		<-s.stopped
+
+		s.lock.Lock()
+		defer s.lock.Unlock()
+
+		err = s.file.Close()
+		s.file = nil
+	})
+
+	return err
+}
+
+// run compacts the log periodically
+func (s *DiskCache[T]) run() {
+	defer close(s.stopped)
+
+	ticker := time.NewTicker(s.options.CompactInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			s.Compact()
+		}
+	}
+}
+
+// open opens the log file and rebuilds the index from its records. The log
+// is truncated at the first invalid record, which can only be the last one
+// if a crash interrupted its write
+func (s *DiskCache[T]) open() error {
+	file, err := os.OpenFile(s.path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return err
+	}
+
+	s.file = file
+	s.index = make(map[string]diskEntry)
+	s.size = 0
+	s.liveSize = 0
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	now := time.Now().UnixMilli()
+	header := make([]byte, diskRecordHeaderSize)
+	for {
+		_, err = file.ReadAt(header, s.size)
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			file.Close()
+			return err
+		}
+
+		keyLength := int64(binary.BigEndian.Uint32(header[13:17]))
+		valueLength := int64(binary.BigEndian.Uint32(header[17:21]))
+		size := diskRecordHeaderSize + keyLength + valueLength
+
+		// the lengths are not checked by the checksum yet, a torn or corrupted
+		// header must not make us allocate more than the file holds
+		if s.size+size > info.Size() {
+			break
+		}
+
+		record := make([]byte, size)
+		_, err = file.ReadAt(record, s.size)
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			file.Close()
+			return err
+		}
+
+		if crc32.ChecksumIEEE(record[4:]) != binary.BigEndian.Uint32(record[:4]) {
+			break
+		}
+
+		key := string(record[diskRecordHeaderSize : diskRecordHeaderSize+keyLength])
+		if old, found := s.index[key]; found {
+			delete(s.index, key)
+			s.liveSize -= old.size
+		}
+
+		expireAt := int64(binary.BigEndian.Uint64(record[5:13]))
+		if record[4] == diskRecordPut && expireAt > now {
+			s.index[key] = diskEntry{offset: s.size, size: size, expireAt: expireAt}
+			s.liveSize += size
+		}
+
+		s.size += size
+	}
+
+	// drop the torn record, if any
+	err = file.Truncate(s.size)
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	return nil
+}
+
+// append writes a record at the end of the log, s.lock must be held
+func (s *DiskCache[T]) append(flags byte, expireAt int64, key string, value []byte) (offset, size int64, err error) {
+	record := encodeDiskRecord(flags, expireAt, key, value)
+
+	_, err = s.file.WriteAt(record, s.size)
+	if err != nil {
+		// drop whatever was partially written
+		s.file.Truncate(s.size)
+		return 0, 0, err
+	}
+
+	if s.options.SyncWrites {
+		err = s.file.Sync()
+		if err != nil {
+			return 0, 0, err
+		}
+	}
+
+	offset = s.size
+	s.size += int64(len(record))
+
+	return offset, int64(len(record)), nil
+}
+
+// remove appends a delete record for the key, s.lock must be held
+func (s *DiskCache[T]) remove(key string) error {
+	e, found := s.index[key]
+	if !found {
+		return nil
+	}
+
+	_, _, err := s.append(diskRecordDelete, 0, key, nil)
+	if err != nil {
+		return err
+	}
+
+	delete(s.index, key)
+	s.liveSize -= e.size
+
+	return nil
+}
+
+// evict removes the entries closest to their expiration until the live
+// records fit in MaxSize again, s.lock must be held
+func (s *DiskCache[T]) evict() error {
+	if s.options.MaxSize <= 0 || s.liveSize <= s.options.MaxSize {
+		return nil
+	}
+
+	keys := make([]string, 0, len(s.index))
+	for key := range s.index {
+		keys = append(keys, key)
+	}
+	slices.SortFunc(keys, func(a, b string) int {
+		return cmp.Compare(s.index[a].expireAt, s.index[b].expireAt)
+	})
+
+	// evict down to 90% of the limit, so that the index is not sorted again
+	// on the next writes
+	target := s.options.MaxSize / 10 * 9
+	for _, key := range keys {
+		if s.liveSize <= target {
+			break
+		}
+
+		err := s.remove(key)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// rewrite copies the live records into a new log file which replaces the
+// current one, s.lock must be held
+func (s *DiskCache[T]) rewrite() error {
+	tempPath := s.path + ".tmp"
+	temp, err := os.OpenFile(tempPath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+
+	index := make(map[string]diskEntry, len(s.index))
+	var size int64
+	for key, e := range s.index {
+		record := make([]byte, e.size)
+		_, err = s.file.ReadAt(record, e.offset)
+		if err == nil {
+			_, err = temp.WriteAt(record, size)
+		}
+		if err != nil {
+			temp.Close()
+			os.Remove(tempPath)
+			return err
+		}
+
+		index[key] = diskEntry{offset: size, size: e.size, expireAt: e.expireAt}
+		size += e.size
+	}
+
+	// the new log must be durable before it replaces the old one
+	err = temp.Sync()
+	if err == nil {
+		err = os.Rename(tempPath, s.path)
+	}
+	if err != nil {
+		temp.Close()
+		os.Remove(tempPath)
+		return err
+	}
+
+	syncDir(filepath.Dir(s.path))
+
+	s.file.Close()
+	s.file = temp
+	s.index = index
+	s.size = size
+	s.liveSize = size
+
+	return nil
+}
+
+// encodeDiskRecord encodes a log record: a checksum of the rest of the record,
+// the flags, the expiration time, the key and value lengths, the key and the
+// value
+func encodeDiskRecord(flags byte, expireAt int64, key string, value []byte) []byte {
+	record := make([]byte, diskRecordHeaderSize+len(key)+len(value))
+	record[4] = flags
+	binary.BigEndian.PutUint64(record[5:13], uint64(expireAt))
+	binary.BigEndian.PutUint32(record[13:17], uint32(len(key)))
+	binary.BigEndian.PutUint32(record[17:21], uint32(len(value)))
+	copy(record[diskRecordHeaderSize:], key)
+	copy(record[diskRecordHeaderSize+len(key):], value)
+	binary.BigEndian.PutUint32(record[:4], crc32.ChecksumIEEE(record[4:]))
+
+	return record
+}
+
+// syncDir makes a rename in the directory durable
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	err = d.Sync()
+	if errors.Is(err, os.ErrInvalid) {
+		// not supported on every platform
+		return nil
+	}
+
+	return err
+}
@@ -0,0 +1,245 @@
+package gocache
+
+import (
+	"context"
+	"encoding/binary"
+	"math"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDiskCache_SetGetDelete(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	ds, err := NewDiskCache[*getResponse](dir, time.Minute, DiskCacheOptions{}, WithKeyPrefix("disk:"))
+	if err != nil {
+		t.Fatalf("NewDiskCache() error = %v", err)
+	}
+
+	ds.Set(ctx, "k1", &getResponse{Value: 1})
+	ds.Set(ctx, "k2", &getResponse{Value: 2})
+	ds.Set(ctx, "k2", &getResponse{Value: 3})
+	ds.Delete(ctx, "k1")
+
+	if _, err := ds.Get(ctx, "k1"); err != ErrRecordNotFound {
+		t.Errorf("DiskCache.Get() deleted error = %v, want = %v", err, ErrRecordNotFound)
+	}
+
+	got, err := ds.Get(ctx, "k2")
+	if err != nil || got.Value != 3 {
+		t.Errorf("DiskCache.Get() got = %v, error = %v, want = %v", got, err, 3)
+	}
+
+	if err := ds.Close(); err != nil {
+		t.Fatalf("DiskCache.Close() error = %v", err)
+	}
+
+	// the entries survive a restart
+	reopened, err := NewDiskCache[*getResponse](dir, time.Minute, DiskCacheOptions{}, WithKeyPrefix("disk:"))
+	if err != nil {
+		t.Fatalf("NewDiskCache() reopen error = %v", err)
+	}
+	defer reopened.Close()
+
+	if _, err := reopened.Get(ctx, "k1"); err != ErrRecordNotFound {
+		t.Errorf("DiskCache.Get() deleted after reopen error = %v, want = %v", err, ErrRecordNotFound)
+	}
+
+	got, err = reopened.Get(ctx, "k2")
+	if err != nil || got.Value != 3 {
+		t.Errorf("DiskCache.Get() after reopen got = %v, error = %v, want = %v", got, err, 3)
+	}
+}
+
+func TestDiskCache_TornWrite(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	ds, err := NewDiskCache[string](dir, time.Minute, DiskCacheOptions{SyncWrites: true})
+	if err != nil {
+		t.Fatalf("NewDiskCache() error = %v", err)
+	}
+	ds.Set(ctx, "k1", "v1")
+	ds.Set(ctx, "k2", "v2")
+	ds.Close()
+
+	// simulate a crash in the middle of the last write
+	path := filepath.Join(dir, diskCacheFileName)
+	info, _ := os.Stat(path)
+	if err := os.Truncate(path, info.Size()-3); err != nil {
+		t.Fatalf("os.Truncate() error = %v", err)
+	}
+
+	reopened, err := NewDiskCache[string](dir, time.Minute, DiskCacheOptions{})
+	if err != nil {
+		t.Fatalf("NewDiskCache() reopen error = %v", err)
+	}
+	defer reopened.Close()
+
+	if got, err := reopened.Get(ctx, "k1"); err != nil || got != "v1" {
+		t.Errorf("DiskCache.Get() got = %v, error = %v, want = %v", got, err, "v1")
+	}
+	if _, err := reopened.Get(ctx, "k2"); err != ErrRecordNotFound {
+		t.Errorf("DiskCache.Get() torn record error = %v, want = %v", err, ErrRecordNotFound)
+	}
+
+	// new writes go after the last valid record
+	reopened.Set(ctx, "k3", "v3")
+	if got, err := reopened.Get(ctx, "k3"); err != nil || got != "v3" {
+		t.Errorf("DiskCache.Get() got = %v, error = %v, want = %v", got, err, "v3")
+	}
+}
+
+func TestDiskCache_CorruptedHeader(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	ds, err := NewDiskCache[string](dir, time.Minute, DiskCacheOptions{SyncWrites: true})
+	if err != nil {
+		t.Fatalf("NewDiskCache() error = %v", err)
+	}
+	ds.Set(ctx, "k1", "v1")
+	ds.Close()
+
+	// a header claiming lengths of 4 GiB each, followed by nothing
+	path := filepath.Join(dir, diskCacheFileName)
+	info, _ := os.Stat(path)
+	header := make([]byte, diskRecordHeaderSize)
+	binary.BigEndian.PutUint32(header[13:17], math.MaxUint32)
+	binary.BigEndian.PutUint32(header[17:21], math.MaxUint32)
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		t.Fatalf("os.OpenFile() error = %v", err)
+	}
+	file.Write(header)
+	file.Close()
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+
+	reopened, err := NewDiskCache[string](dir, time.Minute, DiskCacheOptions{})
+	if err != nil {
+		t.Fatalf("NewDiskCache() reopen error = %v", err)
+	}
+	defer reopened.Close()
+
+	runtime.ReadMemStats(&after)
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 1<<20 {
+		t.Errorf("NewDiskCache() allocated = %v bytes, want less than %v", allocated, 1<<20)
+	}
+
+	if got, err := reopened.Get(ctx, "k1"); err != nil || got != "v1" {
+		t.Errorf("DiskCache.Get() got = %v, error = %v, want = %v", got, err, "v1")
+	}
+
+	// the corrupted header is dropped
+	if reopened, _ := os.Stat(path); reopened.Size() != info.Size() {
+		t.Errorf("log size got = %v, want = %v", reopened.Size(), info.Size())
+	}
+}
+
+func TestDiskCache_Compact(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	ds, err := NewDiskCache[string](dir, 100*time.Millisecond, DiskCacheOptions{CompactInterval: 50 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("NewDiskCache() error = %v", err)
+	}
+	defer ds.Close()
+
+	for _, key := range []string{"k1", "k2", "k3"} {
+		ds.Set(ctx, key, "value")
+	}
+
+	time.Sleep(300 * time.Millisecond)
+
+	if _, err := ds.Get(ctx, "k1"); err != ErrRecordNotFound {
+		t.Errorf("DiskCache.Get() expired error = %v, want = %v", err, ErrRecordNotFound)
+	}
+
+	info, err := os.Stat(filepath.Join(dir, diskCacheFileName))
+	if err != nil || info.Size() != 0 {
+		t.Errorf("log size after compaction got = %v, error = %v, want = %v", info.Size(), err, 0)
+	}
+}
+
+func TestDiskCache_MaxSize(t *testing.T) {
+	ctx := context.Background()
+
+	ds, err := NewDiskCache[string](t.TempDir(), time.Minute, DiskCacheOptions{MaxSize: 200})
+	if err != nil {
+		t.Fatalf("NewDiskCache() error = %v", err)
+	}
+	defer ds.Close()
+	ds.expiryDeviation = 0
+
+	for _, key := range []string{"k1", "k2", "k3", "k4", "k5", "k6", "k7", "k8"} {
+		ds.Set(ctx, key, "value")
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	if ds.liveSize > 200 {
+		t.Errorf("DiskCache live size got = %v, want <= %v", ds.liveSize, 200)
+	}
+
+	// the entries closest to their expiration are evicted first
+	if _, err := ds.Get(ctx, "k1"); err != ErrRecordNotFound {
+		t.Errorf("DiskCache.Get() evicted error = %v, want = %v", err, ErrRecordNotFound)
+	}
+	if got, err := ds.Get(ctx, "k8"); err != nil || got != "value" {
+		t.Errorf("DiskCache.Get() got = %v, error = %v, want = %v", got, err, "value")
+	}
+}
+
+func TestDiskCache_ValueTooLarge(t *testing.T) {
+	ctx := context.Background()
+
+	ds, err := NewDiskCache[string](t.TempDir(), time.Minute, DiskCacheOptions{MaxSize: 100})
+	if err != nil {
+		t.Fatalf("NewDiskCache() error = %v", err)
+	}
+	defer ds.Close()
+
+	if err := ds.Set(ctx, "k1", "value"); err != nil {
+		t.Errorf("DiskCache.Set() error = %v", err)
+	}
+
+	large := strings.Repeat("x", 100)
+	if err := ds.Set(ctx, "k1", large); err != ErrValueTooLarge {
+		t.Errorf("DiskCache.Set() error = %v, want = %v", err, ErrValueTooLarge)
+	}
+
+	// the previous value is kept
+	if got, err := ds.Get(ctx, "k1"); err != nil || got != "value" {
+		t.Errorf("DiskCache.Get() got = %v, error = %v, want = %v", got, err, "value")
+	}
+}
+
+func TestDiskCache_ChainCache(t *testing.T) {
+	ctx := context.Background()
+
+	ms := NewMemoryCache[string](time.Minute)
+	ds, err := NewDiskCache[string](t.TempDir(), time.Hour, DiskCacheOptions{})
+	if err != nil {
+		t.Fatalf("NewDiskCache() error = %v", err)
+	}
+	defer ds.Close()
+
+	ds.Set(ctx, "k1", "v1")
+
+	cs := NewChainCache[string](ms, ds)
+	if got, err := cs.Get(ctx, "k1"); err != nil || got != "v1" {
+		t.Errorf("ChainCache.Get() got = %v, error = %v, want = %v", got, err, "v1")
+	}
+
+	// backfilled into the memory tier
+	if got, err := ms.Get(ctx, "k1"); err != nil || got != "v1" {
+		t.Errorf("MemoryCache.Get() got = %v, error = %v, want = %v", got, err, "v1")
+	}
+}
@@ -0,0 +1,284 @@
+package gocache
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// releaseLockScript deletes the lock KEYS[1] only if it still holds the token
+// ARGV[1], so a leader never releases a lock that expired and was taken over
+var releaseLockScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+type DistributedSingleFlightConfig struct {
+	// prefix of the lock and result keys
+	Prefix string
+	// LockTTL bounds how long a crashed leader can block the other instances
+	LockTTL time.Duration
+	// ResultTTL is how long the leader's result stays readable by followers
+	ResultTTL time.Duration
+	// WaitTimeout is how long a follower waits for the leader's result before
+	// it calls the function by itself
+	WaitTimeout time.Duration
+	// PollInterval is how often a follower checks for the leader's result
+	PollInterval time.Duration
+	// value codec, JSONCodec is used if not set
+	Codec Codec
+	// KeyFunction[T] set by WithFlightKeyFunc
+	keyFunc any
+}
+
+type DistributedSingleFlightOption func(*DistributedSingleFlightConfig)
+
+// WithLockTTL sets how long the lock is held at most, default 10s
+func WithLockTTL(ttl time.Duration) DistributedSingleFlightOption {
+	return func(c *DistributedSingleFlightConfig) {
+		c.LockTTL = ttl
+	}
+}
+
+// WithResultTTL sets how long the result is shared with followers, default 1s
+func WithResultTTL(ttl time.Duration) DistributedSingleFlightOption {
+	return func(c *DistributedSingleFlightConfig) {
+		c.ResultTTL = ttl
+	}
+}
+
+// WithWaitTimeout sets how long followers wait for the leader, default 5s
+func WithWaitTimeout(timeout time.Duration) DistributedSingleFlightOption {
+	return func(c *DistributedSingleFlightConfig) {
+		c.WaitTimeout = timeout
+	}
+}
+
+// WithFlightPrefix sets the prefix of the lock and result keys, default
+// "gocache:flight:"
+func WithFlightPrefix(prefix string) DistributedSingleFlightOption {
+	return func(c *DistributedSingleFlightConfig) {
+		c.Prefix = prefix
+	}
+}
+
+// WithFlightCodec sets the codec of the published results, default JSONCodec
+func WithFlightCodec(codec Codec) DistributedSingleFlightOption {
+	return func(c *DistributedSingleFlightConfig) {
+		c.Codec = codec
+	}
+}
+
+// WithFlightKeyFunc sets the function generating the keys of the arguments,
+// default GenerateCacheKey. It should be the key function of the LoadableCache
+// the single flight is used by
+func WithFlightKeyFunc[T any](fn KeyFunction[T]) DistributedSingleFlightOption {
+	return func(c *DistributedSingleFlightConfig) {
+		c.keyFunc = fn
+	}
+}
+
+// WithPollInterval sets how often followers poll for the result, default 50ms
+func WithPollInterval(interval time.Duration) DistributedSingleFlightOption {
+	return func(c *DistributedSingleFlightConfig) {
+		c.PollInterval = interval
+	}
+}
+
+// DistributedSingleFlight deduplicates calls across instances sharing a redis
+// server. Calls are first deduplicated within the process, then the local
+// leader competes for a redis lock: the winner calls the function and
+// publishes the result in redis, the others poll for that result and fall back
+// to calling the function themselves if it doesn't show up within WaitTimeout.
+// A follower may receive a result published by a previous call up to
+// ResultTTL earlier.
+type DistributedSingleFlight[T, K any] struct {
+	client  *redis.Client
+	config  *DistributedSingleFlightConfig
+	keyFunc KeyFunction[T]
+	local   SingleFlight[T, K]
+}
+
+// NewDistributedSingleFlight returns a single flight shared by all instances
+// using the same redis server
+func NewDistributedSingleFlight[T, K any](client *redis.Client, options ...DistributedSingleFlightOption) *DistributedSingleFlight[T, K] {
+	config := &DistributedSingleFlightConfig{
+		Prefix:       "gocache:flight:",
+		LockTTL:      10 * time.Second,
+		ResultTTL:    time.Second,
+		WaitTimeout:  5 * time.Second,
+		PollInterval: 50 * time.Millisecond,
+	}
+
+	for _, option := range options {
+		option(config)
+	}
+
+	if config.Codec == nil {
+		config.Codec = JSONCodec{}
+	}
+
+	keyFunc := keyFunction[T](&LoadConfig{keyFunc: config.keyFunc})
+
+	return &DistributedSingleFlight[T, K]{
+		client:  client,
+		config:  config,
+		keyFunc: keyFunc,
+		local:   NewSingleFlight[T, K](WithKeyFunc(keyFunc)),
+	}
+}
+
+func (s *DistributedSingleFlight[T, K]) Do(fn LoadFunction[T, K], arg T) (K, error) {
+	val, _, err := s.DoEx(fn, arg)
+	return val, err
+}
+
+func (s *DistributedSingleFlight[T, K]) DoCtx(ctx context.Context, fn LoadFunctionCtx[T, K], arg T) (K, error) {
+	val, _, err := s.DoExCtx(ctx, fn, arg)
+	return val, err
+}
+
+// DoEx calls fn once across all instances, fresh reports whether this caller
+// led the call within its process
+func (s *DistributedSingleFlight[T, K]) DoEx(fn LoadFunction[T, K], arg T) (val K, fresh bool, err error) {
+	return s.DoExCtx(context.Background(), func(ctx context.Context, arg T) (K, error) {
+		return fn(arg)
+	}, arg)
+}
+
+// DoExCtx calls fn once across all instances, fresh reports whether this
+// caller led the call within its process
+func (s *DistributedSingleFlight[T, K]) DoExCtx(ctx context.Context, fn LoadFunctionCtx[T, K], arg T) (val K, fresh bool, err error) {
+	return s.local.DoExCtx(ctx, func(ctx context.Context, arg T) (K, error) {
+		return s.do(ctx, fn, arg)
+	}, arg)
+}
+
+// DoChan is like DoCtx but returns a channel that receives the result when it
+// is ready
+func (s *DistributedSingleFlight[T, K]) DoChan(ctx context.Context, fn LoadFunctionCtx[T, K], arg T) <-chan Result[K] {
+	return s.local.DoChan(ctx, func(ctx context.Context, arg T) (K, error) {
+		return s.do(ctx, fn, arg)
+	}, arg)
+}
+
+// Forget drops the call of arg within the process and the result published in
+// redis, the lock of a running leader is kept
+func (s *DistributedSingleFlight[T, K]) Forget(arg T) {
+	s.local.Forget(arg)
+	s.client.Del(context.Background(), s.config.Prefix+s.keyFunc(arg)+":result")
+}
+
+// do competes for the lock of arg and either calls fn as the leader or waits
+// for the leader's result
+func (s *DistributedSingleFlight[T, K]) do(ctx context.Context, fn LoadFunctionCtx[T, K], arg T) (value K, err error) {
+	key := s.keyFunc(arg)
+	lockKey := s.config.Prefix + key + ":lock"
+	resultKey := s.config.Prefix + key + ":result"
+
+	token, err := newLockToken()
+	if err != nil {
+		return value, err
+	}
+
+	acquired, err := s.client.SetNX(ctx, lockKey, token, s.config.LockTTL).Result()
+	if err != nil {
+		// redis is unavailable, don't let it block the call
+		return fn(ctx, arg)
+	}
+
+	deadline := time.Now().Add(s.config.WaitTimeout)
+	for !acquired {
+		select {
+		case <-ctx.Done():
+			return value, ctx.Err()
+		case <-time.After(s.config.PollInterval):
+		}
+
+		// the leader publishes its result before releasing the lock, so the
+		// result is checked first. If the leader failed there is no result
+		// and one of the followers takes over the lock
+		value, err = s.result(ctx, resultKey)
+		if err == nil {
+			return value, nil
+		}
+
+		if time.Now().After(deadline) {
+			return fn(ctx, arg)
+		}
+
+		acquired, err = s.client.SetNX(ctx, lockKey, token, s.config.LockTTL).Result()
+		if err != nil {
+			return fn(ctx, arg)
+		}
+
+		// the leader may have published its result and released the lock
+		// right after the check above
+		if acquired {
+			value, err = s.result(ctx, resultKey)
+			if err == nil {
+				releaseLockScript.Run(ctx, s.client, []string{lockKey}, token)
+				return value, nil
+			}
+		}
+	}
+
+	return s.lead(ctx, fn, arg, lockKey, resultKey, token)
+}
+
+// lead calls fn while holding the lock and publishes its result
+func (s *DistributedSingleFlight[T, K]) lead(ctx context.Context, fn LoadFunctionCtx[T, K], arg T, lockKey, resultKey, token string) (K, error) {
+	defer func() {
+		// release the lock even if the caller's context is done, otherwise
+		// the other instances wait until the lock expires
+		releaseLockScript.Run(context.WithoutCancel(ctx), s.client, []string{lockKey}, token)
+	}()
+
+	// the result of a previous call must not be taken for ours
+	s.client.Del(ctx, resultKey)
+
+	value, err := fn(ctx, arg)
+	if err != nil {
+		return value, err
+	}
+
+	marshaled, err := s.config.Codec.Marshal(value)
+	if err == nil {
+		s.client.Set(ctx, resultKey, string(marshaled), s.config.ResultTTL)
+	}
+
+	return value, nil
+}
+
+// result returns the result published by the leader
+func (s *DistributedSingleFlight[T, K]) result(ctx context.Context, resultKey string) (value K, err error) {
+	marshaled, err := s.client.Get(ctx, resultKey).Result()
+	if err == redis.Nil {
+		return value, ErrRecordNotFound
+	}
+	if err != nil {
+		return value, err
+	}
+
+	err = s.config.Codec.Unmarshal([]byte(marshaled), &value)
+	if err != nil {
+		return value, err
+	}
+
+	return value, nil
+}
+
+// newLockToken returns a random token identifying the holder of a lock
+func newLockToken() (string, error) {
+	buffer := make([]byte, 16)
+	if _, err := rand.Read(buffer); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(buffer), nil
+}
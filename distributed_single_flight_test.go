@@ -0,0 +1,163 @@
+package gocache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDistributedSingleFlight_DoCtx(t *testing.T) {
+	client := requireRedis(t)
+	ctx := context.Background()
+
+	// each single flight stands for a separate instance
+	instances := make([]*DistributedSingleFlight[*addRequest, *addResponse], 5)
+	for index := range instances {
+		instances[index] = NewDistributedSingleFlight[*addRequest, *addResponse](client, withTestFlightPrefix(t))
+	}
+
+	s := new(score)
+	wg := &sync.WaitGroup{}
+	for index := 0; index < 100; index++ {
+		wg.Add(1)
+		go func(sf *DistributedSingleFlight[*addRequest, *addResponse]) {
+			defer wg.Done()
+
+			response, err := sf.DoCtx(ctx, s.AddCtx, &addRequest{Value: 1})
+			if err != nil {
+				t.Errorf("DistributedSingleFlight.DoCtx() error = %v", err)
+				return
+			}
+
+			if response.Total != 1 {
+				t.Errorf("DistributedSingleFlight.DoCtx() = %v, want %v", response.Total, 1)
+			}
+		}(instances[index%len(instances)])
+	}
+
+	wg.Wait()
+
+	if s.total != 1 {
+		t.Errorf("DistributedSingleFlight.DoCtx() called the function %d times, want %d", s.total, 1)
+	}
+}
+
+func TestDistributedSingleFlight_LeaderFailure(t *testing.T) {
+	client := requireRedis(t)
+	ctx := context.Background()
+
+	prefix := withTestFlightPrefix(t)
+	leader := NewDistributedSingleFlight[string, string](client, prefix)
+	follower := NewDistributedSingleFlight[string, string](client, prefix, WithPollInterval(10*time.Millisecond))
+
+	started := make(chan struct{})
+	errLoad := errors.New("load failed")
+	var calls int64
+
+	go leader.DoCtx(ctx, func(ctx context.Context, arg string) (string, error) {
+		atomic.AddInt64(&calls, 1)
+		close(started)
+		time.Sleep(100 * time.Millisecond)
+		return "", errLoad
+	}, "k1")
+
+	<-started
+
+	// the follower takes over once the leader releases the lock
+	got, err := follower.DoCtx(ctx, func(ctx context.Context, arg string) (string, error) {
+		atomic.AddInt64(&calls, 1)
+		return "v1", nil
+	}, "k1")
+	if err != nil || got != "v1" {
+		t.Errorf("DistributedSingleFlight.DoCtx() got = %v, error = %v, want = %v", got, err, "v1")
+	}
+
+	if calls != 2 {
+		t.Errorf("DistributedSingleFlight.DoCtx() calls = %d, want = %d", calls, 2)
+	}
+}
+
+func TestDistributedSingleFlight_LoadableCache(t *testing.T) {
+	client := requireRedis(t)
+
+	sf := NewDistributedSingleFlight[string, string](client, withTestFlightPrefix(t))
+	lc := NewLoadableCacheWithSingleFlight[string, string](NewMemoryCache[string](time.Minute), sf)
+
+	a := appender{}
+	a.Append("v1")
+
+	got, err := lc.Load(a.Get, "k1")
+	if err != nil || got != "v1" {
+		t.Errorf("LoadableCache.Load() got = %v, error = %v, want = %v", got, err, "v1")
+	}
+}
+
+// countingCodec counts the values it encodes
+type countingCodec struct {
+	JSONCodec
+	marshaled *atomic.Int32
+}
+
+func (c countingCodec) Marshal(v any) ([]byte, error) {
+	c.marshaled.Add(1)
+	return c.JSONCodec.Marshal(v)
+}
+
+func TestDistributedSingleFlight_Options(t *testing.T) {
+	client := requireRedis(t)
+	ctx := context.Background()
+
+	type user struct {
+		ID    int
+		Trace string
+	}
+
+	prefix := "gocache:test:" + t.Name() + ":"
+	codec := countingCodec{marshaled: &atomic.Int32{}}
+	sf := NewDistributedSingleFlight[user, string](client,
+		WithFlightPrefix(prefix),
+		WithFlightCodec(codec),
+		WithFlightKeyFunc(KeyTemplate[user]("user:{ID}")),
+		WithResultTTL(time.Minute),
+	)
+	lc := NewLoadableCacheWithSingleFlight[user, string](NewMemoryCache[string](time.Minute), sf,
+		WithKeyFunc(KeyTemplate[user]("user:{ID}")))
+
+	got, err := lc.LoadCtx(ctx, func(ctx context.Context, arg user) (string, error) {
+		return "v1", nil
+	}, user{ID: 1, Trace: "t1"})
+	if err != nil || got != "v1" {
+		t.Errorf("LoadableCache.LoadCtx() got = %v, error = %v, want = %v", got, err, "v1")
+	}
+
+	// the result is published under the key of the key function
+	if n, _ := client.Exists(ctx, prefix+"user:1:result").Result(); n != 1 {
+		t.Errorf("result key exists = %v, want = %v", n, 1)
+	}
+	if n := codec.marshaled.Load(); n != 1 {
+		t.Errorf("countingCodec.Marshal() calls = %v, want = %v", n, 1)
+	}
+
+	sf.Forget(user{ID: 1, Trace: "t2"})
+	if n, _ := client.Exists(ctx, prefix+"user:1:result").Result(); n != 0 {
+		t.Errorf("result key exists after Forget = %v, want = %v", n, 0)
+	}
+}
+
+func TestNewDistributedSingleFlight_KeyFuncTypeMismatch(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("NewDistributedSingleFlight() did not panic")
+		}
+	}()
+
+	NewDistributedSingleFlight[string, string](nil, WithFlightKeyFunc(KeyTemplate[struct{ ID int }]("{ID}")))
+}
+
+// withTestFlightPrefix isolates the redis keys of a test
+func withTestFlightPrefix(t *testing.T) DistributedSingleFlightOption {
+	return WithFlightPrefix("gocache:test:" + t.Name() + ":")
+}
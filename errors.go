@@ -0,0 +1,82 @@
+package gocache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+)
+
+var (
+	// ErrCodec is matched by the errors of encoding and decoding values
+	ErrCodec = errors.New("codec error")
+	// ErrBackendUnavailable is matched by the errors of the backends of the
+	// caches, such as network, database or I/O failures
+	ErrBackendUnavailable = errors.New("backend unavailable")
+	// ErrLoadPanic is matched by the PanicError of a function that panicked
+	ErrLoadPanic = errors.New("load function panic")
+)
+
+// PanicError is the error of a load or single flight function that panicked
+type PanicError struct {
+	// value passed to panic
+	Value any
+	// stack of the goroutine when it panicked
+	Stack []byte
+}
+
+func newPanicError(value any) *PanicError {
+	return &PanicError{Value: value, Stack: debug.Stack()}
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("load function panic: %v", e.Value)
+}
+
+func (e *PanicError) Is(target error) bool {
+	return target == ErrLoadPanic
+}
+
+// Unwrap returns the panic value if it is an error
+func (e *PanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+// backendError wraps the error of a backend with ErrBackendUnavailable, the
+// errors the backend didn't cause are returned as they are
+func backendError(err error) error {
+	if err == nil ||
+		errors.Is(err, ErrRecordNotFound) ||
+		errors.Is(err, ErrCodec) ||
+		errors.Is(err, ErrClosed) ||
+		errors.Is(err, context.Canceled) ||
+		errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+
+	return fmt.Errorf("%w: %w", ErrBackendUnavailable, err)
+}
+
+// codecErrors wraps the errors of a codec with ErrCodec
+type codecErrors struct {
+	codec Codec
+}
+
+func (c codecErrors) Marshal(v any) ([]byte, error) {
+	data, err := c.codec.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrCodec, err)
+	}
+
+	return data, nil
+}
+
+func (c codecErrors) Unmarshal(data []byte, v any) error {
+	err := c.codec.Unmarshal(data, v)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrCodec, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,114 @@
+package gocache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+// failingCodec fails to encode and decode every value
+type failingCodec struct{}
+
+func (failingCodec) Marshal(v any) ([]byte, error) {
+	return nil, errors.New("cannot marshal")
+}
+
+func (failingCodec) Unmarshal(data []byte, v any) error {
+	return errors.New("cannot unmarshal")
+}
+
+// wrappingCache wraps the not found errors of its cache like a custom Cache
+// implementation might do
+type wrappingCache[T any] struct {
+	Cache[T]
+}
+
+func (c wrappingCache[T]) Get(ctx context.Context, key string) (T, error) {
+	value, err := c.Cache.Get(ctx, key)
+	if err != nil {
+		return value, fmt.Errorf("custom cache get %s: %w", key, err)
+	}
+
+	return value, nil
+}
+
+func TestPanicError(t *testing.T) {
+	ctx := context.Background()
+	boom := errors.New("boom")
+
+	tests := []struct {
+		name string
+		call func() error
+	}{
+		{"LoadableCache", func() error {
+			lc := NewLoadableCache[string, string](NewMemoryCache[string](time.Minute))
+			_, err := lc.LoadCtx(ctx, func(ctx context.Context, arg string) (string, error) {
+				panic(boom)
+			}, "k1")
+			return err
+		}},
+		{"SingleFlight", func() error {
+			sf := NewSingleFlight[string, string]()
+			_, err := sf.DoCtx(ctx, func(ctx context.Context, arg string) (string, error) {
+				panic(boom)
+			}, "k1")
+			return err
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.call()
+			if !errors.Is(err, ErrLoadPanic) {
+				t.Fatalf("error got = %v, want = %v", err, ErrLoadPanic)
+			}
+
+			// the panic value is reachable as well
+			if !errors.Is(err, boom) {
+				t.Errorf("error got = %v, want = %v", err, boom)
+			}
+
+			var panicErr *PanicError
+			if !errors.As(err, &panicErr) || panicErr.Value != boom || len(panicErr.Stack) == 0 {
+				t.Errorf("errors.As() got = %+v", panicErr)
+			}
+		})
+	}
+}
+
+func TestCodecAndBackendErrors(t *testing.T) {
+	ctx := context.Background()
+	server := newFakeMemcached(t)
+
+	codecCache := NewMemcachedCache[string](memcache.New(server.listener.Addr().String()), time.Minute, WithCodec(failingCodec{}))
+	if err := codecCache.Set(ctx, "k1", "v1"); !errors.Is(err, ErrCodec) || errors.Is(err, ErrBackendUnavailable) {
+		t.Errorf("MemcachedCache.Set() error = %v, want = %v", err, ErrCodec)
+	}
+
+	// nothing listens on the address any more
+	listener := newFakeMemcached(t).listener
+	listener.Close()
+
+	unavailable := NewMemcachedCache[string](memcache.New(listener.Addr().String()), time.Minute)
+	if _, err := unavailable.Get(ctx, "k1"); !errors.Is(err, ErrBackendUnavailable) {
+		t.Errorf("MemcachedCache.Get() error = %v, want = %v", err, ErrBackendUnavailable)
+	}
+	if err := unavailable.Set(ctx, "k1", "v1"); !errors.Is(err, ErrBackendUnavailable) {
+		t.Errorf("MemcachedCache.Set() error = %v, want = %v", err, ErrBackendUnavailable)
+	}
+}
+
+func TestChainCache_WrappedNotFound(t *testing.T) {
+	ctx := context.Background()
+
+	last := NewMemoryCache[string](time.Minute)
+	last.Set(ctx, "k1", "v1")
+
+	cs := NewChainCache[string](wrappingCache[string]{NewMemoryCache[string](time.Minute)}, last)
+	if got, err := cs.Get(ctx, "k1"); err != nil || got != "v1" {
+		t.Errorf("ChainCache.Get() got = %v, error = %v, want = %v", got, err, "v1")
+	}
+}
@@ -1,14 +1,21 @@
 module github.com/nzai/gocache
 
-go 1.24
+go 1.24.0
 
 require (
+	github.com/bradfitz/gomemcache v0.0.0-20230905024940-24af94b03874
+	github.com/cespare/xxhash/v2 v2.3.0
+	github.com/mattn/go-sqlite3 v1.14.33
 	github.com/nzai/timewheel v0.1.1
 	github.com/redis/go-redis/v9 v9.22.0
+	google.golang.org/grpc v1.76.0
+	google.golang.org/protobuf v1.36.6
 )
 
 require (
-	github.com/cespare/xxhash/v2 v2.3.0 // indirect
 	go.uber.org/atomic v1.11.0 // indirect
-	golang.org/x/sys v0.30.0 // indirect
+	golang.org/x/net v0.42.0 // indirect
+	golang.org/x/sys v0.34.0 // indirect
+	golang.org/x/text v0.27.0 // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20250804133106-a7a43d27e69b // indirect
 )
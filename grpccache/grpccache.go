@@ -0,0 +1,284 @@
+// Package grpccache provides a gRPC unary client interceptor caching the
+// responses of selected methods in a gocache.Cache.
+package grpccache
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/nzai/gocache"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// BypassMetadataKey is the outgoing metadata key that makes the interceptor
+// skip the cache lookup when set to "true"; the response is still stored.
+// The key is removed before the call is sent.
+const BypassMetadataKey = "x-gocache-bypass"
+
+// authorizationMetadataKey is the outgoing metadata key of the credentials,
+// calls carrying it are not cached unless it is a key metadata
+const authorizationMetadataKey = "authorization"
+
+type Config struct {
+	// time to live of the responses of the cached methods, keyed by full
+	// method name such as "/grpc.health.v1.Health/Check". The responses live
+	// at most as long as the expiration of the cache
+	Methods map[string]time.Duration
+	// options of the single flight deduplicating concurrent misses
+	LoadOptions []gocache.LoadOption
+	// outgoing metadata keys whose values are part of the cache key, in lower
+	// case
+	KeyMetadata []string
+}
+
+type Option func(*Config)
+
+// WithMethod caches the responses of the method for ttl
+func WithMethod(method string, ttl time.Duration) Option {
+	if ttl <= 0 {
+		panic("grpccache: WithMethod ttl must be positive")
+	}
+
+	return func(c *Config) {
+		c.Methods[method] = ttl
+	}
+}
+
+// WithLoadOptions sets the options of the single flight deduplicating
+// concurrent misses, such as gocache.WithShareWindow
+func WithLoadOptions(options ...gocache.LoadOption) Option {
+	return func(c *Config) {
+		c.LoadOptions = append(c.LoadOptions, options...)
+	}
+}
+
+// WithKeyMetadata makes the values of the outgoing metadata keys part of the
+// cache key, so that calls differing in them, such as calls of different
+// tenants, don't share responses. Calls with "authorization" metadata are not
+// cached unless it is one of the keys
+func WithKeyMetadata(keys ...string) Option {
+	return func(c *Config) {
+		for _, key := range keys {
+			c.KeyMetadata = append(c.KeyMetadata, strings.ToLower(key))
+		}
+	}
+}
+
+// Bypass returns a context whose calls skip the cache lookup
+func Bypass(ctx context.Context) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, BypassMetadataKey, "true")
+}
+
+type interceptor struct {
+	config       *Config
+	cache        gocache.Cache[[]byte]
+	singleFlight gocache.SingleFlight[flightCall, []byte]
+}
+
+// flightCall is the argument of the single flight, keyed by the cache key
+type flightCall struct {
+	key     string
+	ttl     time.Duration
+	method  string
+	request any
+	reply   proto.Message
+	cc      *grpc.ClientConn
+	invoker grpc.UnaryInvoker
+	options []grpc.CallOption
+}
+
+func (c flightCall) GetCacheKey() string {
+	return c.key
+}
+
+// UnaryClientInterceptor returns an interceptor caching the responses of the
+// configured methods, keyed by method, deterministically marshaled request and
+// the values of the key metadata. Concurrent misses for the same key send one
+// request. Calls with "authorization" metadata or per-call credentials are
+// sent without the cache, credentials set on the connection can't be seen by
+// the interceptor. Call options reading the response metadata, such as
+// grpc.Header, are not filled when the response comes from the cache.
+func UnaryClientInterceptor(cache gocache.Cache[[]byte], options ...Option) grpc.UnaryClientInterceptor {
+	config := &Config{Methods: make(map[string]time.Duration)}
+	for _, option := range options {
+		option(config)
+	}
+
+	i := &interceptor{
+		config:       config,
+		cache:        cache,
+		singleFlight: gocache.NewSingleFlight[flightCall, []byte](config.LoadOptions...),
+	}
+
+	return i.intercept
+}
+
+func (i *interceptor) intercept(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	ctx, bypass := removeBypass(ctx)
+
+	ttl, found := i.config.Methods[method]
+	if !found {
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+
+	requestMessage, ok := req.(proto.Message)
+	if !ok {
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+
+	replyMessage, ok := reply.(proto.Message)
+	if !ok {
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+
+	if i.authorized(ctx, opts) {
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+
+	marshaled, err := proto.MarshalOptions{Deterministic: true}.Marshal(requestMessage)
+	if err != nil {
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+	key := method + ":" + i.key(ctx, marshaled)
+
+	if !bypass {
+		response, found := i.lookup(ctx, key)
+		if found && proto.Unmarshal(response, replyMessage) == nil {
+			return nil
+		}
+	}
+
+	response, fresh, err := i.singleFlight.DoExCtx(ctx, i.load, flightCall{
+		key:     key,
+		ttl:     ttl,
+		method:  method,
+		request: req,
+		reply:   replyMessage,
+		cc:      cc,
+		invoker: invoker,
+		options: opts,
+	})
+	if err != nil {
+		code := status.Code(err)
+		if !fresh && ctx.Err() == nil && (code == codes.Canceled || code == codes.DeadlineExceeded) {
+			// the leader was cancelled, send the request alone
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+
+		// a follower whose context ended gets the bare context error
+		if _, ok := status.FromError(err); !ok && (errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)) {
+			return status.FromContextError(err).Err()
+		}
+
+		return err
+	}
+
+	return proto.Unmarshal(response, replyMessage)
+}
+
+// authorized reports whether the call carries credentials that are not part
+// of the cache key
+func (i *interceptor) authorized(ctx context.Context, opts []grpc.CallOption) bool {
+	for _, opt := range opts {
+		if _, ok := opt.(grpc.PerRPCCredsCallOption); ok {
+			return true
+		}
+	}
+
+	md, _ := metadata.FromOutgoingContext(ctx)
+	if len(md.Get(authorizationMetadataKey)) == 0 {
+		return false
+	}
+
+	for _, key := range i.config.KeyMetadata {
+		if key == authorizationMetadataKey {
+			return false
+		}
+	}
+
+	return true
+}
+
+// key returns the cache key of the marshaled request and the key metadata
+func (i *interceptor) key(ctx context.Context, marshaled []byte) string {
+	if len(i.config.KeyMetadata) == 0 {
+		return gocache.GenerateCacheKey(marshaled)
+	}
+
+	md, _ := metadata.FromOutgoingContext(ctx)
+	values := make([][]string, len(i.config.KeyMetadata))
+	for index, key := range i.config.KeyMetadata {
+		values[index] = md.Get(key)
+	}
+
+	return gocache.GenerateCacheKey(struct {
+		Request  []byte
+		Metadata [][]string
+	}{marshaled, values})
+}
+
+// lookup returns the fresh cached response of the key
+func (i *interceptor) lookup(ctx context.Context, key string) ([]byte, bool) {
+	entry, err := i.cache.Get(ctx, key)
+	if err != nil || len(entry) < 8 {
+		return nil, false
+	}
+
+	// the entry starts with the expiration time in unix milliseconds
+	expireAt := int64(binary.BigEndian.Uint64(entry[:8]))
+	if time.Now().UnixMilli() >= expireAt {
+		return nil, false
+	}
+
+	return entry[8:], true
+}
+
+// load sends the request and stores the response
+func (i *interceptor) load(ctx context.Context, call flightCall) ([]byte, error) {
+	reply := call.reply.ProtoReflect().New().Interface()
+
+	err := call.invoker(ctx, call.method, call.request, reply, call.cc, call.options...)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := proto.MarshalOptions{Deterministic: true}.Marshal(reply)
+	if err != nil {
+		return nil, err
+	}
+
+	entry := make([]byte, 8, 8+len(response))
+	binary.BigEndian.PutUint64(entry, uint64(time.Now().Add(call.ttl).UnixMilli()))
+	entry = append(entry, response...)
+
+	// failing to store the response must not fail the call
+	i.cache.Set(ctx, call.key, entry)
+
+	return response, nil
+}
+
+// removeBypass removes the bypass flag from the outgoing metadata and reports
+// whether it was set
+func removeBypass(ctx context.Context) (context.Context, bool) {
+	md, found := metadata.FromOutgoingContext(ctx)
+	if !found {
+		return ctx, false
+	}
+
+	values := md.Get(BypassMetadataKey)
+	if len(values) == 0 {
+		return ctx, false
+	}
+
+	md = md.Copy()
+	md.Delete(BypassMetadataKey)
+
+	return metadata.NewOutgoingContext(ctx, md), values[len(values)-1] == "true"
+}
@@ -0,0 +1,257 @@
+package grpccache
+
+import (
+	"context"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nzai/gocache"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+const checkMethod = "/grpc.health.v1.Health/Check"
+
+// countingHealthServer counts its calls and reports SERVING for the service
+// "serving" only
+type countingHealthServer struct {
+	healthpb.UnimplementedHealthServer
+
+	calls    atomic.Int64
+	latency  time.Duration
+	metadata chan metadata.MD
+}
+
+func (s *countingHealthServer) Check(ctx context.Context, req *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
+	s.calls.Add(1)
+	time.Sleep(s.latency)
+
+	if s.metadata != nil {
+		md, _ := metadata.FromIncomingContext(ctx)
+		s.metadata <- md
+	}
+
+	if req.Service == "serving" {
+		return &healthpb.HealthCheckResponse{Status: healthpb.HealthCheckResponse_SERVING}, nil
+	}
+
+	return &healthpb.HealthCheckResponse{Status: healthpb.HealthCheckResponse_NOT_SERVING}, nil
+}
+
+func newTestClient(t *testing.T, server *countingHealthServer, options ...Option) healthpb.HealthClient {
+	listener := bufconn.Listen(1 << 20)
+
+	s := grpc.NewServer()
+	healthpb.RegisterHealthServer(s, server)
+	go s.Serve(listener)
+	t.Cleanup(s.Stop)
+
+	cache := gocache.NewMemoryCache[[]byte](time.Minute)
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithUnaryInterceptor(UnaryClientInterceptor(cache, options...)),
+	)
+	if err != nil {
+		t.Fatalf("grpc.NewClient() error = %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return healthpb.NewHealthClient(conn)
+}
+
+func TestUnaryClientInterceptor(t *testing.T) {
+	ctx := context.Background()
+	server := &countingHealthServer{}
+	client := newTestClient(t, server, WithMethod(checkMethod, time.Minute))
+
+	tests := []struct {
+		service   string
+		want      healthpb.HealthCheckResponse_ServingStatus
+		wantCalls int64
+	}{
+		{"serving", healthpb.HealthCheckResponse_SERVING, 1},
+		{"serving", healthpb.HealthCheckResponse_SERVING, 1},
+		{"other", healthpb.HealthCheckResponse_NOT_SERVING, 2},
+		{"other", healthpb.HealthCheckResponse_NOT_SERVING, 2},
+		{"serving", healthpb.HealthCheckResponse_SERVING, 2},
+	}
+	for _, tt := range tests {
+		response, err := client.Check(ctx, &healthpb.HealthCheckRequest{Service: tt.service})
+		if err != nil || response.Status != tt.want {
+			t.Errorf("HealthClient.Check(%v) got = %v, error = %v, want = %v", tt.service, response.GetStatus(), err, tt.want)
+		}
+		if calls := server.calls.Load(); calls != tt.wantCalls {
+			t.Errorf("server calls got = %v, want = %v", calls, tt.wantCalls)
+		}
+	}
+}
+
+func TestUnaryClientInterceptor_TTL(t *testing.T) {
+	ctx := context.Background()
+	server := &countingHealthServer{}
+	client := newTestClient(t, server, WithMethod(checkMethod, 100*time.Millisecond))
+
+	request := &healthpb.HealthCheckRequest{Service: "serving"}
+	client.Check(ctx, request)
+	client.Check(ctx, request)
+	time.Sleep(150 * time.Millisecond)
+	client.Check(ctx, request)
+
+	if calls := server.calls.Load(); calls != 2 {
+		t.Errorf("server calls got = %v, want = %v", calls, 2)
+	}
+}
+
+func TestUnaryClientInterceptor_NotCached(t *testing.T) {
+	ctx := context.Background()
+	server := &countingHealthServer{}
+	client := newTestClient(t, server)
+
+	request := &healthpb.HealthCheckRequest{Service: "serving"}
+	client.Check(ctx, request)
+	client.Check(ctx, request)
+
+	if calls := server.calls.Load(); calls != 2 {
+		t.Errorf("server calls got = %v, want = %v", calls, 2)
+	}
+}
+
+func TestUnaryClientInterceptor_Bypass(t *testing.T) {
+	ctx := context.Background()
+	server := &countingHealthServer{metadata: make(chan metadata.MD, 2)}
+	client := newTestClient(t, server, WithMethod(checkMethod, time.Minute))
+
+	request := &healthpb.HealthCheckRequest{Service: "serving"}
+	client.Check(ctx, request)
+	<-server.metadata
+
+	if _, err := client.Check(Bypass(ctx), request); err != nil {
+		t.Fatalf("HealthClient.Check() error = %v", err)
+	}
+	if calls := server.calls.Load(); calls != 2 {
+		t.Errorf("server calls got = %v, want = %v", calls, 2)
+	}
+
+	// the flag is not sent to the server
+	if md := <-server.metadata; len(md.Get(BypassMetadataKey)) != 0 {
+		t.Errorf("server metadata got = %v, want no %v", md, BypassMetadataKey)
+	}
+
+	// the bypassed response refreshed the cache
+	client.Check(ctx, request)
+	if calls := server.calls.Load(); calls != 2 {
+		t.Errorf("server calls got = %v, want = %v", calls, 2)
+	}
+}
+
+func TestUnaryClientInterceptor_SingleFlight(t *testing.T) {
+	ctx := context.Background()
+	server := &countingHealthServer{latency: 100 * time.Millisecond}
+	client := newTestClient(t, server, WithMethod(checkMethod, time.Minute))
+
+	wg := new(sync.WaitGroup)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			response, err := client.Check(ctx, &healthpb.HealthCheckRequest{Service: "serving"})
+			if err != nil || response.Status != healthpb.HealthCheckResponse_SERVING {
+				t.Errorf("HealthClient.Check() got = %v, error = %v, want = %v", response.GetStatus(), err, healthpb.HealthCheckResponse_SERVING)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if calls := server.calls.Load(); calls != 1 {
+		t.Errorf("server calls got = %v, want = %v", calls, 1)
+	}
+}
+
+func TestUnaryClientInterceptor_KeyMetadata(t *testing.T) {
+	server := &countingHealthServer{}
+	client := newTestClient(t, server, WithMethod(checkMethod, time.Minute), WithKeyMetadata("X-Tenant"))
+	request := &healthpb.HealthCheckRequest{Service: "serving"}
+
+	tests := []struct {
+		tenant    string
+		wantCalls int64
+	}{
+		{"a", 1},
+		{"a", 1},
+		{"b", 2},
+		{"b", 2},
+	}
+	for _, tt := range tests {
+		ctx := metadata.AppendToOutgoingContext(context.Background(), "x-tenant", tt.tenant)
+		if _, err := client.Check(ctx, request); err != nil {
+			t.Errorf("HealthClient.Check() error = %v", err)
+		}
+		if calls := server.calls.Load(); calls != tt.wantCalls {
+			t.Errorf("server calls got = %v, want = %v", calls, tt.wantCalls)
+		}
+	}
+}
+
+func TestUnaryClientInterceptor_Authorization(t *testing.T) {
+	tests := []struct {
+		name      string
+		options   []Option
+		tokens    []string
+		wantCalls int64
+	}{
+		{"not cached", nil, []string{"t1", "t1"}, 2},
+		{"keyed by token", []Option{WithKeyMetadata("Authorization")}, []string{"t1", "t1", "t2"}, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := &countingHealthServer{}
+			client := newTestClient(t, server, append(tt.options, WithMethod(checkMethod, time.Minute))...)
+
+			for _, token := range tt.tokens {
+				ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+token)
+				if _, err := client.Check(ctx, &healthpb.HealthCheckRequest{Service: "serving"}); err != nil {
+					t.Errorf("HealthClient.Check() error = %v", err)
+				}
+			}
+
+			if calls := server.calls.Load(); calls != tt.wantCalls {
+				t.Errorf("server calls got = %v, want = %v", calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestUnaryClientInterceptor_FollowerDeadline(t *testing.T) {
+	server := &countingHealthServer{latency: 200 * time.Millisecond}
+	client := newTestClient(t, server, WithMethod(checkMethod, time.Minute))
+	request := &healthpb.HealthCheckRequest{Service: "serving"}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		client.Check(context.Background(), request)
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err := client.Check(ctx, request)
+	if code := status.Code(err); code != codes.DeadlineExceeded {
+		t.Errorf("HealthClient.Check() code got = %v, want = %v, error = %v", code, codes.DeadlineExceeded, err)
+	}
+
+	<-done
+}
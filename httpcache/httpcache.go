@@ -0,0 +1,371 @@
+// Package httpcache provides net/http middleware caching whole GET responses
+// in a gocache.Cache.
+package httpcache
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nzai/gocache"
+)
+
+// CachedResponse is a response stored in the cache
+type CachedResponse struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+	// request headers the response varies on, set alone on the entry stored
+	// under the key without the request headers
+	Vary []string
+	// time after which the response is stale, zero means it is fresh until
+	// the cache drops it
+	ExpiresAt time.Time
+}
+
+type Config struct {
+	// time to live of responses without max-age, zero means they are kept
+	// until the cache drops them
+	DefaultTTL time.Duration
+	// options of the single flight deduplicating concurrent misses
+	LoadOptions []gocache.LoadOption
+}
+
+type Option func(*Config)
+
+// WithDefaultTTL sets the time to live of responses without max-age
+func WithDefaultTTL(ttl time.Duration) Option {
+	return func(c *Config) {
+		c.DefaultTTL = ttl
+	}
+}
+
+// WithLoadOptions sets the options of the single flight deduplicating
+// concurrent misses, such as gocache.WithShareWindow
+func WithLoadOptions(options ...gocache.LoadOption) Option {
+	return func(c *Config) {
+		c.LoadOptions = append(c.LoadOptions, options...)
+	}
+}
+
+// cacheableStatusCodes are the status codes whose responses are stored
+var cacheableStatusCodes = map[int]bool{
+	http.StatusOK:                   true,
+	http.StatusNonAuthoritativeInfo: true,
+	http.StatusNoContent:            true,
+	http.StatusMultipleChoices:      true,
+	http.StatusMovedPermanently:     true,
+	http.StatusNotFound:             true,
+	http.StatusGone:                 true,
+}
+
+type middleware struct {
+	config       *Config
+	cache        gocache.Cache[CachedResponse]
+	next         http.Handler
+	singleFlight gocache.SingleFlight[flightRequest, *flightResult]
+}
+
+// flightRequest is the argument of the single flight, keyed by the request
+// key without the request headers
+type flightRequest struct {
+	key     string
+	request *http.Request
+}
+
+func (r flightRequest) GetCacheKey() string {
+	return r.key
+}
+
+// flightResult is the response of the handler shared by the single flight
+type flightResult struct {
+	response CachedResponse
+	// key of the response including the request headers it varies on
+	key       string
+	cacheable bool
+}
+
+// Middleware returns a middleware caching the GET responses of the next
+// handler. Requests are keyed on method, host, URL and the request headers
+// listed in the Vary header of the response. Cache-Control is honoured on both
+// sides: no-store requests bypass the cache, no-cache requests skip the
+// lookup, and responses with no-store, no-cache, private, max-age=0,
+// Set-Cookie or Vary: * are not stored. Requests with an Authorization header
+// are never served from the cache. Concurrent misses for the same key call
+// the next handler once.
+func Middleware(cache gocache.Cache[CachedResponse], options ...Option) func(http.Handler) http.Handler {
+	config := &Config{}
+	for _, option := range options {
+		option(config)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return &middleware{
+			config:       config,
+			cache:        cache,
+			next:         next,
+			singleFlight: gocache.NewSingleFlight[flightRequest, *flightResult](config.LoadOptions...),
+		}
+	}
+}
+
+func (m *middleware) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	directives := parseCacheControl(r.Header.Get("Cache-Control"))
+	if r.Method != http.MethodGet || r.Header.Get("Authorization") != "" || directives.has("no-store") {
+		m.next.ServeHTTP(w, r)
+		return
+	}
+
+	if !directives.has("no-cache") {
+		response, found := m.lookup(r.Context(), r)
+		if found {
+			serve(w, r, response)
+			return
+		}
+	}
+
+	result, fresh, err := m.singleFlight.DoExCtx(r.Context(), m.load, flightRequest{key: requestKey(r, nil), request: r})
+	if err != nil {
+		if !fresh {
+			// the leader failed or was cancelled, handle the request alone
+			m.next.ServeHTTP(w, r)
+			return
+		}
+
+		if r.Context().Err() == nil {
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	// a response that must not be stored must not be shared either, and a
+	// response varying on request headers is only shared by equal requests
+	if !fresh && (!result.cacheable || requestKey(r, result.response.Vary) != result.key) {
+		m.next.ServeHTTP(w, r)
+		return
+	}
+
+	serve(w, r, result.response)
+}
+
+// lookup returns the fresh cached response of the request
+func (m *middleware) lookup(ctx context.Context, r *http.Request) (CachedResponse, bool) {
+	response, err := m.cache.Get(ctx, requestKey(r, nil))
+	if err != nil {
+		return response, false
+	}
+
+	if response.StatusCode == 0 && len(response.Vary) > 0 {
+		response, err = m.cache.Get(ctx, requestKey(r, response.Vary))
+		if err != nil {
+			return response, false
+		}
+	}
+
+	if !response.ExpiresAt.IsZero() && !time.Now().Before(response.ExpiresAt) {
+		return response, false
+	}
+
+	return response, true
+}
+
+// load calls the next handler and stores its response if allowed
+func (m *middleware) load(ctx context.Context, arg flightRequest) (*flightResult, error) {
+	// conditional requests are answered by the middleware from the full
+	// response, so the next handler must not answer them
+	request := arg.request.Clone(ctx)
+	request.Header.Del("If-None-Match")
+	request.Header.Del("If-Modified-Since")
+
+	recorder := &responseRecorder{header: make(http.Header)}
+	m.next.ServeHTTP(recorder, request)
+
+	if recorder.statusCode == 0 {
+		recorder.statusCode = http.StatusOK
+	}
+
+	result := &flightResult{
+		response: CachedResponse{
+			StatusCode: recorder.statusCode,
+			Header:     recorder.header,
+			Body:       recorder.body.Bytes(),
+			Vary:       varyHeaders(recorder.header),
+		},
+	}
+	result.key = requestKey(arg.request, result.response.Vary)
+
+	ttl, cacheable := m.ttl(result.response)
+	if !cacheable {
+		return result, nil
+	}
+	result.cacheable = true
+
+	if ttl > 0 {
+		result.response.ExpiresAt = time.Now().Add(ttl)
+	}
+
+	// failing to store the response must not fail the request
+	if len(result.response.Vary) > 0 {
+		m.cache.Set(ctx, arg.key, CachedResponse{Vary: result.response.Vary})
+	}
+	m.cache.Set(ctx, result.key, result.response)
+
+	return result, nil
+}
+
+// ttl returns the time to live of the response and whether it can be stored
+func (m *middleware) ttl(response CachedResponse) (time.Duration, bool) {
+	if !cacheableStatusCodes[response.StatusCode] {
+		return 0, false
+	}
+
+	if response.Header.Get("Set-Cookie") != "" || slices.Contains(response.Vary, "*") {
+		return 0, false
+	}
+
+	directives := parseCacheControl(response.Header.Get("Cache-Control"))
+	if directives.has("no-store") || directives.has("no-cache") || directives.has("private") {
+		return 0, false
+	}
+
+	for _, name := range []string{"s-maxage", "max-age"} {
+		value, found := directives[name]
+		if !found {
+			continue
+		}
+
+		seconds, err := strconv.Atoi(value)
+		if err != nil || seconds <= 0 {
+			return 0, false
+		}
+
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	return m.config.DefaultTTL, true
+}
+
+// serve writes the cached response, or 304 Not Modified if it matches the
+// If-None-Match header of the request
+func serve(w http.ResponseWriter, r *http.Request, response CachedResponse) {
+	header := w.Header()
+	for name, values := range response.Header {
+		header[name] = slices.Clone(values)
+	}
+
+	etag := response.Header.Get("ETag")
+	if etag != "" && matchETag(r.Header.Get("If-None-Match"), etag) {
+		header.Del("Content-Length")
+		header.Del("Content-Type")
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.WriteHeader(response.StatusCode)
+	w.Write(response.Body)
+}
+
+// requestKey returns the cache key of the request including the values of
+// the vary headers
+func requestKey(r *http.Request, vary []string) string {
+	key := struct {
+		Method string
+		URL    string
+		Vary   []string
+	}{
+		Method: r.Method,
+		URL:    r.Host + r.URL.RequestURI(),
+	}
+
+	for _, name := range vary {
+		key.Vary = append(key.Vary, name+"="+strings.Join(r.Header.Values(name), ","))
+	}
+
+	return gocache.GenerateCacheKey(key)
+}
+
+// varyHeaders returns the sorted canonical names listed in the Vary header
+func varyHeaders(header http.Header) []string {
+	var names []string
+	for _, value := range header.Values("Vary") {
+		for _, name := range strings.Split(value, ",") {
+			name = strings.TrimSpace(name)
+			if name != "" {
+				names = append(names, http.CanonicalHeaderKey(name))
+			}
+		}
+	}
+
+	slices.Sort(names)
+	return slices.Compact(names)
+}
+
+// matchETag reports whether the If-None-Match header matches the etag, using
+// the weak comparison
+func matchETag(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+
+	if strings.TrimSpace(ifNoneMatch) == "*" {
+		return true
+	}
+
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		if strings.TrimPrefix(strings.TrimSpace(candidate), "W/") == etag {
+			return true
+		}
+	}
+
+	return false
+}
+
+// cacheControl holds the directives of a Cache-Control header
+type cacheControl map[string]string
+
+func parseCacheControl(header string) cacheControl {
+	directives := make(cacheControl)
+	for _, directive := range strings.Split(header, ",") {
+		directive = strings.TrimSpace(directive)
+		if directive == "" {
+			continue
+		}
+
+		name, value, _ := strings.Cut(directive, "=")
+		directives[strings.ToLower(strings.TrimSpace(name))] = strings.Trim(strings.TrimSpace(value), `"`)
+	}
+
+	return directives
+}
+
+func (c cacheControl) has(name string) bool {
+	_, found := c[name]
+	return found
+}
+
+// responseRecorder buffers the response of the next handler
+type responseRecorder struct {
+	header     http.Header
+	statusCode int
+	body       bytes.Buffer
+}
+
+func (r *responseRecorder) Header() http.Header {
+	return r.header
+}
+
+func (r *responseRecorder) WriteHeader(statusCode int) {
+	if r.statusCode == 0 {
+		r.statusCode = statusCode
+	}
+}
+
+func (r *responseRecorder) Write(data []byte) (int, error) {
+	r.WriteHeader(http.StatusOK)
+	return r.body.Write(data)
+}
@@ -0,0 +1,209 @@
+package httpcache
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nzai/gocache"
+)
+
+// countingHandler counts its calls and writes the call number in the body
+type countingHandler struct {
+	calls   atomic.Int64
+	header  http.Header
+	status  int
+	latency time.Duration
+}
+
+func (h *countingHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	calls := h.calls.Add(1)
+	time.Sleep(h.latency)
+
+	for name, values := range h.header {
+		w.Header()[name] = values
+	}
+
+	if h.status != 0 {
+		w.WriteHeader(h.status)
+	}
+	fmt.Fprintf(w, "%d", calls)
+}
+
+func newTestServer(handler http.Handler, options ...Option) http.Handler {
+	cache := gocache.NewMemoryCache[CachedResponse](time.Minute)
+	return Middleware(cache, options...)(handler)
+}
+
+func get(server http.Handler, url string, header http.Header) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, url, nil)
+	for name, values := range header {
+		r.Header[name] = values
+	}
+
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, r)
+	return w
+}
+
+func TestMiddleware_CacheControl(t *testing.T) {
+	tests := []struct {
+		name          string
+		status        int
+		cacheControl  string
+		requestHeader http.Header
+		wantBody      string
+	}{
+		{"default", 0, "", nil, "1"},
+		{"max-age", 0, "max-age=60", nil, "1"},
+		{"no-store", 0, "no-store", nil, "2"},
+		{"private", 0, "private, max-age=60", nil, "2"},
+		{"max-age zero", 0, "max-age=0", nil, "2"},
+		{"server error", http.StatusInternalServerError, "", nil, "2"},
+		{"request no-store", 0, "", http.Header{"Cache-Control": {"no-store"}}, "2"},
+		{"request no-cache", 0, "", http.Header{"Cache-Control": {"no-cache"}}, "2"},
+		{"authorization", 0, "", http.Header{"Authorization": {"Bearer token"}}, "2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := &countingHandler{status: tt.status, header: http.Header{}}
+			if tt.cacheControl != "" {
+				handler.header.Set("Cache-Control", tt.cacheControl)
+			}
+			server := newTestServer(handler)
+
+			get(server, "/items?id=1", tt.requestHeader)
+			w := get(server, "/items?id=1", tt.requestHeader)
+
+			if got := w.Body.String(); got != tt.wantBody {
+				t.Errorf("Middleware() body got = %v, want = %v", got, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestMiddleware_Expiration(t *testing.T) {
+	handler := &countingHandler{}
+	server := newTestServer(handler, WithDefaultTTL(100*time.Millisecond))
+
+	get(server, "/items", nil)
+	if got := get(server, "/items", nil).Body.String(); got != "1" {
+		t.Errorf("Middleware() body got = %v, want = %v", got, "1")
+	}
+
+	time.Sleep(150 * time.Millisecond)
+
+	if got := get(server, "/items", nil).Body.String(); got != "2" {
+		t.Errorf("Middleware() body after expiration got = %v, want = %v", got, "2")
+	}
+
+	// another URL is another entry
+	if got := get(server, "/items?page=2", nil).Body.String(); got != "3" {
+		t.Errorf("Middleware() body got = %v, want = %v", got, "3")
+	}
+}
+
+func TestMiddleware_Vary(t *testing.T) {
+	handler := &countingHandler{header: http.Header{"Vary": {"Accept-Language"}}}
+	server := newTestServer(handler)
+
+	english := http.Header{"Accept-Language": {"en"}}
+	chinese := http.Header{"Accept-Language": {"zh"}}
+
+	tests := []struct {
+		header   http.Header
+		wantBody string
+	}{
+		{english, "1"},
+		{chinese, "2"},
+		{english, "1"},
+		{chinese, "2"},
+	}
+	for _, tt := range tests {
+		if got := get(server, "/items", tt.header).Body.String(); got != tt.wantBody {
+			t.Errorf("Middleware() body for %v got = %v, want = %v", tt.header, got, tt.wantBody)
+		}
+	}
+}
+
+func TestMiddleware_ETag(t *testing.T) {
+	handler := &countingHandler{header: http.Header{"Etag": {`"v1"`}, "Content-Type": {"text/plain"}}}
+	server := newTestServer(handler)
+
+	tests := []struct {
+		name        string
+		ifNoneMatch string
+		wantStatus  int
+	}{
+		{"no condition", "", http.StatusOK},
+		{"match", `"v1"`, http.StatusNotModified},
+		{"weak match", `"v0", W/"v1"`, http.StatusNotModified},
+		{"no match", `"v0"`, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+			if tt.ifNoneMatch != "" {
+				header.Set("If-None-Match", tt.ifNoneMatch)
+			}
+
+			w := get(server, "/items", header)
+			if w.Code != tt.wantStatus {
+				t.Errorf("Middleware() status got = %v, want = %v", w.Code, tt.wantStatus)
+			}
+			if w.Header().Get("ETag") != `"v1"` {
+				t.Errorf("Middleware() ETag got = %v, want = %v", w.Header().Get("ETag"), `"v1"`)
+			}
+		})
+	}
+
+	if calls := handler.calls.Load(); calls != 1 {
+		t.Errorf("handler calls got = %v, want = %v", calls, 1)
+	}
+}
+
+func TestMiddleware_SingleFlight(t *testing.T) {
+	handler := &countingHandler{latency: 100 * time.Millisecond}
+	server := newTestServer(handler)
+
+	wg := new(sync.WaitGroup)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			if got := get(server, "/items", nil).Body.String(); got != "1" {
+				t.Errorf("Middleware() body got = %v, want = %v", got, "1")
+			}
+		}()
+	}
+	wg.Wait()
+
+	if calls := handler.calls.Load(); calls != 1 {
+		t.Errorf("handler calls got = %v, want = %v", calls, 1)
+	}
+}
+
+func TestMiddleware_SingleFlight_NotShared(t *testing.T) {
+	// private responses are never given to other requests
+	handler := &countingHandler{latency: 100 * time.Millisecond, header: http.Header{"Cache-Control": {"private"}}}
+	server := newTestServer(handler)
+
+	wg := new(sync.WaitGroup)
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			get(server, "/items", nil)
+		}()
+	}
+	wg.Wait()
+
+	if calls := handler.calls.Load(); calls != 5 {
+		t.Errorf("handler calls got = %v, want = %v", calls, 5)
+	}
+}
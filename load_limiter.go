@@ -0,0 +1,130 @@
+package gocache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+var ErrLoadQueueTimeout = errors.New("load queue timeout")
+
+// loadLimiter bounds the number of concurrent loads and their rate
+type loadLimiter struct {
+	// semaphore of the concurrent loads, nil means no limit
+	slots chan struct{}
+	// rate limit of the loads, nil means no limit
+	bucket *tokenBucket
+}
+
+func newLoadLimiter(maxConcurrent int, rate float64, burst int) *loadLimiter {
+	l := &loadLimiter{}
+	if maxConcurrent > 0 {
+		l.slots = make(chan struct{}, maxConcurrent)
+	}
+	if rate > 0 {
+		l.bucket = newTokenBucket(rate, burst)
+	}
+
+	return l
+}
+
+// acquire waits until a load can start and returns the function releasing
+// its slot. It returns ErrLoadQueueTimeout if the context deadline passes
+// while waiting
+func (l *loadLimiter) acquire(ctx context.Context) (release func(), err error) {
+	if l.bucket != nil {
+		if err = l.bucket.wait(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	if l.slots == nil {
+		return func() {}, nil
+	}
+
+	select {
+	case l.slots <- struct{}{}:
+		return func() { <-l.slots }, nil
+	case <-ctx.Done():
+		return nil, queueError(ctx)
+	}
+}
+
+// tokenBucket is a token bucket rate limiter
+type tokenBucket struct {
+	lock sync.Mutex
+	// tokens added per second
+	rate float64
+	// maximum number of tokens
+	burst float64
+	// available tokens, negative when tokens are reserved by waiters
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate float64, burst int) *tokenBucket {
+	if burst < 1 {
+		burst = 1
+	}
+
+	return &tokenBucket{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// wait takes a token, waiting for it if the bucket is empty
+func (b *tokenBucket) wait(ctx context.Context) error {
+	b.lock.Lock()
+	now := time.Now()
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+
+	// reserve the token, it becomes available once the bucket refills
+	b.tokens--
+	delay := time.Duration(-b.tokens / b.rate * float64(time.Second))
+	b.lock.Unlock()
+
+	if delay <= 0 {
+		return nil
+	}
+
+	// don't wait for a token that comes after the deadline
+	if deadline, ok := ctx.Deadline(); ok && deadline.Before(now.Add(delay)) {
+		b.cancel()
+		return ErrLoadQueueTimeout
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		b.cancel()
+		return queueError(ctx)
+	}
+}
+
+// cancel gives back a reserved token
+func (b *tokenBucket) cancel() {
+	b.lock.Lock()
+	b.tokens++
+	b.lock.Unlock()
+}
+
+// queueError returns the error of a wait interrupted by the context
+func queueError(ctx context.Context) error {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return ErrLoadQueueTimeout
+	}
+
+	return ctx.Err()
+}
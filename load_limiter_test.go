@@ -0,0 +1,133 @@
+package gocache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLoadableCache_MaxConcurrentLoads(t *testing.T) {
+	ctx := context.Background()
+	lc := NewLoadableCache[string, string](NewMemoryCache[string](time.Minute), WithMaxConcurrentLoads(2))
+
+	var running, peak int64
+	fn := func(ctx context.Context, arg string) (string, error) {
+		current := atomic.AddInt64(&running, 1)
+		for {
+			old := atomic.LoadInt64(&peak)
+			if current <= old || atomic.CompareAndSwapInt64(&peak, old, current) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		atomic.AddInt64(&running, -1)
+		return arg, nil
+	}
+
+	wg := &sync.WaitGroup{}
+	for index := 0; index < 10; index++ {
+		wg.Add(1)
+		go func(key string) {
+			defer wg.Done()
+
+			got, err := lc.LoadCtx(ctx, fn, key)
+			if err != nil || got != key {
+				t.Errorf("LoadableCache.LoadCtx() got = %v, error = %v, want = %v", got, err, key)
+			}
+		}(fmt.Sprintf("k%d", index))
+	}
+	wg.Wait()
+
+	if peak != 2 {
+		t.Errorf("concurrent loads peak = %v, want = %v", peak, 2)
+	}
+}
+
+func TestLoadableCache_MaxConcurrentLoads_QueueTimeout(t *testing.T) {
+	lc := NewLoadableCache[string, string](NewMemoryCache[string](time.Minute), WithMaxConcurrentLoads(1))
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	go lc.LoadCtx(context.Background(), func(ctx context.Context, arg string) (string, error) {
+		close(started)
+		<-release
+		return arg, nil
+	}, "k1")
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err := lc.LoadCtx(ctx, func(ctx context.Context, arg string) (string, error) {
+		return arg, nil
+	}, "k2")
+	if err != ErrLoadQueueTimeout {
+		t.Errorf("LoadableCache.LoadCtx() error = %v, want = %v", err, ErrLoadQueueTimeout)
+	}
+
+	close(release)
+}
+
+func TestLoadableCache_MaxConcurrentLoads_LoadTimeout(t *testing.T) {
+	lc := NewLoadableCache[string, string](NewMemoryCache[string](time.Minute),
+		WithMaxConcurrentLoads(1), WithLoadTimeout(20*time.Millisecond))
+
+	// the load ignores its context and outlives the timeout
+	release := make(chan struct{})
+	_, err := lc.LoadCtx(context.Background(), func(ctx context.Context, arg string) (string, error) {
+		<-release
+		return arg, nil
+	}, "k1")
+	if !errors.Is(err, ErrLoadTimeout) {
+		t.Errorf("LoadableCache.LoadCtx() error = %v, want = %v", err, ErrLoadTimeout)
+	}
+
+	// the abandoned load still holds its slot
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err = lc.LoadCtx(ctx, func(ctx context.Context, arg string) (string, error) {
+		return arg, nil
+	}, "k2")
+	if err != ErrLoadQueueTimeout {
+		t.Errorf("LoadableCache.LoadCtx() error = %v, want = %v", err, ErrLoadQueueTimeout)
+	}
+
+	// and gives it back when it returns
+	close(release)
+
+	got, err := lc.LoadCtx(context.Background(), func(ctx context.Context, arg string) (string, error) {
+		return arg, nil
+	}, "k3")
+	if err != nil || got != "k3" {
+		t.Errorf("LoadableCache.LoadCtx() got = %v, error = %v, want = %v", got, err, "k3")
+	}
+}
+
+func TestTokenBucket_Wait(t *testing.T) {
+	ctx := context.Background()
+	b := newTokenBucket(20, 2)
+
+	// the burst is available immediately, the next tokens come every 50ms
+	start := time.Now()
+	for index := 0; index < 4; index++ {
+		if err := b.wait(ctx); err != nil {
+			t.Errorf("tokenBucket.wait() error = %v", err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond || elapsed > 500*time.Millisecond {
+		t.Errorf("tokenBucket.wait() took %v, want about 100ms", elapsed)
+	}
+
+	// a token that comes after the deadline is not waited for
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+
+	if err := b.wait(ctx); err != ErrLoadQueueTimeout {
+		t.Errorf("tokenBucket.wait() error = %v, want = %v", err, ErrLoadQueueTimeout)
+	}
+}
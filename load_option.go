@@ -0,0 +1,198 @@
+package gocache
+
+import (
+	"context"
+	"time"
+)
+
+// KeyFunction returns the cache key of arg
+type KeyFunction[T any] func(arg T) string
+
+// FallbackFunction returns a degraded value for arg when the load function
+// failed with err
+type FallbackFunction[T, K any] func(ctx context.Context, arg T, err error) (K, error)
+
+// LoadConfig configures SingleFlight and LoadableCache, a SingleFlight
+// ignores the settings that only apply to LoadableCache
+type LoadConfig struct {
+	// run the function with a context detached from the first caller's
+	// cancellation, so that one cancelled caller doesn't fail the others
+	DetachLoad bool
+	// keep the result of a successful call for this long, so that callers
+	// arriving just after it returned share it instead of calling again
+	ShareWindow time.Duration
+	// maximum duration of a load, 0 means no limit
+	LoadTimeout time.Duration
+	// how long fallback values are cached, 0 means they are not cached
+	FallbackTTL time.Duration
+	// circuit breaker wrapping the load function, nil means no breaker
+	CircuitBreaker *CircuitBreaker
+	// how long loaded values are kept to be served while the circuit is
+	// open, 0 means they are not kept
+	StaleTTL time.Duration
+	// retry policy of failed loads, nil means no retry
+	Retry *RetryPolicy
+	// maximum number of concurrent loads, 0 means no limit
+	MaxConcurrentLoads int
+	// maximum number of loads per second and burst size, 0 means no limit
+	LoadRate  float64
+	LoadBurst int
+
+	// write-behind settings, nil means that Store writes through
+	WriteBehind *WriteBehindConfig
+
+	// KeyFunction[T] of the SingleFlight and LoadableCache
+	keyFunc any
+	// encoder of the keys when there is no key function
+	keyEncoder *KeyEncoder
+	// FallbackFunction[T, K] of the LoadableCache
+	fallback any
+	// SaveFunction[T, K] of the LoadableCache
+	save any
+	// BatchSaveFunction[T, K] of the LoadableCache
+	batchSave any
+}
+
+type LoadOption func(*LoadConfig)
+
+// WithDetachedLoad makes DoCtx and DoExCtx run the function in the background
+// with a context that keeps the first caller's values but is never cancelled.
+// Every caller, the first one included, stops waiting when its own context is
+// done while the function keeps running for the others.
+func WithDetachedLoad() LoadOption {
+	return func(c *LoadConfig) {
+		c.DetachLoad = true
+	}
+}
+
+// WithKeyFunc sets the function generating the keys of the arguments instead
+// of GenerateCacheKey, for argument types that don't implement
+// CacheKeyGenerator. The type of the function must match the argument type of
+// the SingleFlight or LoadableCache.
+func WithKeyFunc[T any](fn KeyFunction[T]) LoadOption {
+	return func(c *LoadConfig) {
+		c.keyFunc = fn
+	}
+}
+
+// WithKeyEncoder generates the keys of the arguments with the given encoder
+// instead of DefaultKeyEncoder. Arguments of type string or implementing
+// CacheKeyGenerator keep their own keys, and WithKeyFunc takes precedence.
+func WithKeyEncoder(encoder *KeyEncoder) LoadOption {
+	if encoder == nil {
+		panic("gocache: WithKeyEncoder encoder must not be nil")
+	}
+
+	return func(c *LoadConfig) {
+		c.keyEncoder = encoder
+	}
+}
+
+// WithShareWindow keeps the result of a successful call for the given window
+// after it returns, callers arriving within the window get it as a shared
+// result. Errors are never kept.
+func WithShareWindow(window time.Duration) LoadOption {
+	return func(c *LoadConfig) {
+		c.ShareWindow = window
+	}
+}
+
+// WithLoadTimeout bounds the duration of the load function of a LoadableCache.
+// The load function gets a context with the deadline, if it ignores it the
+// load returns ErrLoadTimeout anyway and its result is discarded.
+func WithLoadTimeout(timeout time.Duration) LoadOption {
+	return func(c *LoadConfig) {
+		c.LoadTimeout = timeout
+	}
+}
+
+// WithFallback sets the function called by a LoadableCache when a load fails
+// or times out, the value it returns is returned instead of the load error.
+// The types of the function must match the ones of the LoadableCache.
+func WithFallback[T, K any](fn FallbackFunction[T, K]) LoadOption {
+	return func(c *LoadConfig) {
+		c.fallback = fn
+	}
+}
+
+// WithFallbackTTL caches the values returned by the fallback function for the
+// given duration, so that a failing backend is not called for every miss
+func WithFallbackTTL(ttl time.Duration) LoadOption {
+	return func(c *LoadConfig) {
+		c.FallbackTTL = ttl
+	}
+}
+
+// WithCircuitBreaker wraps the load function of a LoadableCache with the given
+// circuit breaker. While the circuit is open loads fail with ErrCircuitOpen,
+// which is handled like any other load error by the stale values and the
+// fallback function if they are set. The breaker can be shared by several
+// caches loading from the same backend.
+func WithCircuitBreaker(breaker *CircuitBreaker) LoadOption {
+	return func(c *LoadConfig) {
+		c.CircuitBreaker = breaker
+	}
+}
+
+// WithStaleTTL keeps every loaded value for the given duration, which should
+// be longer than the cache expiration, and serves it while the circuit of the
+// circuit breaker is open
+func WithStaleTTL(ttl time.Duration) LoadOption {
+	return func(c *LoadConfig) {
+		c.StaleTTL = ttl
+	}
+}
+
+// WithRetry retries the failed loads of a LoadableCache according to the
+// policy. The retries happen within the single flight, so the callers waiting
+// for the load share them instead of retrying on their own.
+func WithRetry(policy RetryPolicy) LoadOption {
+	return func(c *LoadConfig) {
+		c.Retry = &policy
+	}
+}
+
+// WithMaxConcurrentLoads bounds the number of loads of a LoadableCache running
+// at the same time, loads of distinct keys wait for a free slot. A load whose
+// context deadline passes while waiting fails with ErrLoadQueueTimeout.
+func WithMaxConcurrentLoads(n int) LoadOption {
+	return func(c *LoadConfig) {
+		c.MaxConcurrentLoads = n
+	}
+}
+
+// WithLoadRateLimit bounds the number of loads of a LoadableCache started per
+// second, allowing bursts of up to burst loads. A load whose context deadline
+// passes while waiting fails with ErrLoadQueueTimeout.
+func WithLoadRateLimit(rate float64, burst int) LoadOption {
+	return func(c *LoadConfig) {
+		c.LoadRate = rate
+		c.LoadBurst = burst
+	}
+}
+
+// WithSaveFunction sets the function used by LoadableCache.Store to write
+// values to the backing store. The types of the function must match the ones
+// of the LoadableCache.
+func WithSaveFunction[T, K any](fn SaveFunction[T, K]) LoadOption {
+	return func(c *LoadConfig) {
+		c.save = fn
+	}
+}
+
+// WithBatchSaveFunction sets the function used to flush the buffered writes
+// of a write-behind LoadableCache, without it the writes are flushed one by
+// one with the save function
+func WithBatchSaveFunction[T, K any](fn BatchSaveFunction[T, K]) LoadOption {
+	return func(c *LoadConfig) {
+		c.batchSave = fn
+	}
+}
+
+// WithWriteBehind makes LoadableCache.Store update the cache immediately and
+// buffer the writes to the backing store, which are flushed in batches
+func WithWriteBehind(config WriteBehindConfig) LoadOption {
+	return func(c *LoadConfig) {
+		c.WriteBehind = &config
+	}
+}
@@ -2,82 +2,219 @@ package gocache
 
 import (
 	"context"
+	"errors"
 	"fmt"
 )
 
+var ErrLoadTimeout = errors.New("load timeout")
+
 type LoadFunction[T, K any] func(T) (K, error)
 type LoadFunctionCtx[T, K any] func(context.Context, T) (K, error)
 
 type LoadableCache[T, K any] struct {
-	cache        Cache[K]
-	singleFlight SingleFlight[T, K]
+	config        *LoadConfig
+	cache         Cache[K]
+	singleFlight  SingleFlight[T, K]
+	keyFunc       KeyFunction[T]
+	fallback      FallbackFunction[T, K]
+	fallbackCache Cache[K]
+	staleCache    Cache[K]
+	limiter       *loadLimiter
+	save          SaveFunction[T, K]
+	writeBehind   *writeBehind[T, K]
 }
 
 // NewLoadableCache instantiates a new cache that uses a function to load data
-func NewLoadableCache[T, K any](cache Cache[K]) *LoadableCache[T, K] {
-	return &LoadableCache[T, K]{
+func NewLoadableCache[T, K any](cache Cache[K], options ...LoadOption) *LoadableCache[T, K] {
+	return NewLoadableCacheWithSingleFlight[T, K](cache, NewSingleFlight[T, K](options...), options...)
+}
+
+// NewLoadableCacheWithSingleFlight instantiates a new cache that uses a
+// function to load data and the given single flight to deduplicate the loads,
+// e.g. a DistributedSingleFlight to deduplicate them across instances
+func NewLoadableCacheWithSingleFlight[T, K any](cache Cache[K], singleFlight SingleFlight[T, K], options ...LoadOption) *LoadableCache[T, K] {
+	c := &LoadableCache[T, K]{
+		config:       &LoadConfig{},
 		cache:        cache,
-		singleFlight: NewSingleFlight[T, K](),
+		singleFlight: singleFlight,
 	}
-}
 
-// Load returns the object stored in cache
-func (c *LoadableCache[T, K]) Load(fn LoadFunction[T, K], arg T) (K, error) {
-	ctx := context.Background()
-	key := GenerateCacheKey(arg)
-	value, err := c.cache.Get(ctx, key)
-	if err == nil {
-		return value, nil
+	for _, option := range options {
+		option(c.config)
 	}
 
-	return c.singleFlight.Do(func(arg T) (value K, err error) {
-		defer func() {
-			if err1 := recover(); err1 != nil {
-				err = fmt.Errorf("load function panic: %v", err1)
-			}
-		}()
+	c.keyFunc = keyFunction[T](c.config)
 
-		// because load function is an IO query ,which is slower than cache get
-		// so we do double check, cache might be taken by another call
-		value, err = c.cache.Get(ctx, key)
-		if err == nil {
-			return value, nil
+	if c.config.fallback != nil {
+		fallback, ok := c.config.fallback.(FallbackFunction[T, K])
+		if !ok {
+			panic(fmt.Sprintf("gocache: NewLoadableCache fallback must be a %T", fallback))
 		}
+		c.fallback = fallback
 
-		// Unable to find in cache, try to load it from load function
-		object, err := fn(arg)
-		if err != nil {
-			// TODO: we may need to cache errors to reduce the access to the backend
-			return object, err
+		if c.config.FallbackTTL > 0 {
+			c.fallbackCache = NewMemoryCache[K](c.config.FallbackTTL)
 		}
+	}
 
-		// Then, put it back in cache
-		c.cache.Set(ctx, key, object)
+	if c.config.StaleTTL > 0 {
+		c.staleCache = NewMemoryCache[K](c.config.StaleTTL)
+	}
 
-		return object, nil
+	if c.config.MaxConcurrentLoads > 0 || c.config.LoadRate > 0 {
+		c.limiter = newLoadLimiter(c.config.MaxConcurrentLoads, c.config.LoadRate, c.config.LoadBurst)
+	}
+
+	if c.config.save != nil {
+		save, ok := c.config.save.(SaveFunction[T, K])
+		if !ok {
+			panic(fmt.Sprintf("gocache: NewLoadableCache save function must be a %T", save))
+		}
+		c.save = save
+	}
+
+	if c.config.WriteBehind != nil {
+		var batchSave BatchSaveFunction[T, K]
+		if c.config.batchSave != nil {
+			var ok bool
+			batchSave, ok = c.config.batchSave.(BatchSaveFunction[T, K])
+			if !ok {
+				panic(fmt.Sprintf("gocache: NewLoadableCache batch save function must be a %T", batchSave))
+			}
+		} else if c.save != nil {
+			batchSave = c.saveEach
+		} else {
+			panic("gocache: NewLoadableCache write-behind requires a save function")
+		}
+
+		c.writeBehind = newWriteBehind(*c.config.WriteBehind, batchSave)
+	}
+
+	return c
+}
+
+// Load returns the object stored in cache
+func (c *LoadableCache[T, K]) Load(fn LoadFunction[T, K], arg T) (K, error) {
+	return c.LoadCtx(context.Background(), func(ctx context.Context, arg T) (K, error) {
+		return fn(arg)
 	}, arg)
 }
 
 // Delete removes the object from cache, the next Load will hit the load function
 func (c *LoadableCache[T, K]) Delete(ctx context.Context, arg T) error {
-	return c.cache.Delete(ctx, GenerateCacheKey(arg))
+	key := c.keyFunc(arg)
+	if c.fallbackCache != nil {
+		c.fallbackCache.Delete(ctx, key)
+	}
+	if c.staleCache != nil {
+		c.staleCache.Delete(ctx, key)
+	}
+
+	err := c.cache.Delete(ctx, key)
+
+	// a result shared by WithShareWindow would outlive the deleted value
+	c.singleFlight.Forget(arg)
+
+	return err
+}
+
+// Store writes the value to the backing store with the save function and puts
+// it in cache. In write-behind mode the value is put in cache immediately and
+// written to the backing store by a later flush
+func (c *LoadableCache[T, K]) Store(ctx context.Context, arg T, value K) error {
+	key := c.keyFunc(arg)
+	if c.writeBehind != nil {
+		err := c.writeBehind.add(key, WriteEntry[T, K]{Arg: arg, Value: value})
+		if err != nil {
+			return err
+		}
+
+		return c.put(ctx, arg, key, value)
+	}
+
+	if c.save == nil {
+		return ErrNotSupported
+	}
+
+	err := c.save(ctx, arg, value)
+	if err != nil {
+		return err
+	}
+
+	return c.put(ctx, arg, key, value)
+}
+
+// Flush writes the pending writes of the write-behind mode to the backing store
+func (c *LoadableCache[T, K]) Flush(ctx context.Context) error {
+	if c.writeBehind == nil {
+		return nil
+	}
+
+	return c.writeBehind.flush(ctx)
+}
+
+// Close drains the pending writes of the write-behind mode and releases the
+// resources of the cache, the cache must not be used after Close is called
+func (c *LoadableCache[T, K]) Close(ctx context.Context) error {
+	var err error
+	if c.writeBehind != nil {
+		err = c.writeBehind.close(ctx)
+	}
+
+	for _, cache := range []Cache[K]{c.fallbackCache, c.staleCache} {
+		if mc, ok := cache.(*MemoryCache[K]); ok {
+			mc.Stop()
+		}
+	}
+
+	return err
+}
+
+// put stores a value written by Store in cache, replacing the values kept
+// for a failing backend and the result shared by the single flight
+func (c *LoadableCache[T, K]) put(ctx context.Context, arg T, key string, value K) error {
+	if c.fallbackCache != nil {
+		c.fallbackCache.Delete(ctx, key)
+	}
+	if c.staleCache != nil {
+		c.staleCache.Set(ctx, key, value)
+	}
+
+	err := c.cache.Set(ctx, key, value)
+	c.singleFlight.Forget(arg)
+
+	return err
+}
+
+// saveEach writes the entries one by one with the save function
+func (c *LoadableCache[T, K]) saveEach(ctx context.Context, entries []WriteEntry[T, K]) error {
+	var errs []error
+	for _, entry := range entries {
+		if err := c.save(ctx, entry.Arg, entry.Value); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
 }
 
 // LoadCtx returns the object stored in cache with context
 func (c *LoadableCache[T, K]) LoadCtx(ctx context.Context, fn LoadFunctionCtx[T, K], arg T) (K, error) {
-	key := GenerateCacheKey(arg)
+	key := c.keyFunc(arg)
 	value, err := c.cache.Get(ctx, key)
 	if err == nil {
 		return value, err
 	}
 
-	return c.singleFlight.DoCtx(ctx, func(ctx context.Context, arg T) (value K, err error) {
-		defer func() {
-			if err1 := recover(); err1 != nil {
-				err = fmt.Errorf("load function panic: %v", err1)
-			}
-		}()
+	// a recent load failed, keep serving its fallback value
+	if c.fallbackCache != nil {
+		value, err = c.fallbackCache.Get(ctx, key)
+		if err == nil {
+			return value, nil
+		}
+	}
 
+	return c.singleFlight.DoCtx(ctx, func(ctx context.Context, arg T) (value K, err error) {
 		// because load function is an IO query ,which is slower than cache get
 		// so we do double check, cache might be taken by another call
 		value, err = c.cache.Get(ctx, key)
@@ -86,15 +223,138 @@ func (c *LoadableCache[T, K]) LoadCtx(ctx context.Context, fn LoadFunctionCtx[T,
 		}
 
 		// Unable to find in cache, try to load it from load function
-		object, err := fn(ctx, arg)
+		object, err := c.load(ctx, fn, arg)
 		if err != nil {
 			// TODO: we may need to cache errors to reduce the access to the backend
-			return object, err
+			return c.loadFallback(ctx, key, arg, err)
 		}
 
 		// Then, put it back in cache
 		c.cache.Set(ctx, key, object)
+		if c.staleCache != nil {
+			c.staleCache.Set(ctx, key, object)
+		}
 
 		return object, nil
 	}, arg)
 }
+
+// load waits for the load limiter and calls the load function through the
+// circuit breaker
+func (c *LoadableCache[T, K]) load(ctx context.Context, fn LoadFunctionCtx[T, K], arg T) (value K, err error) {
+	release := func() {}
+	if c.limiter != nil {
+		release, err = c.limiter.acquire(ctx)
+		if err != nil {
+			return value, err
+		}
+	}
+
+	if c.config.CircuitBreaker == nil {
+		return c.loadWithTimeout(ctx, fn, arg, release)
+	}
+
+	done, err := c.config.CircuitBreaker.Allow()
+	if err != nil {
+		release()
+		return value, err
+	}
+
+	value, err = c.loadWithTimeout(ctx, fn, arg, release)
+	done(err)
+
+	return value, err
+}
+
+// loadWithTimeout calls the load function within the load timeout. release is
+// called when the load function returns, which may be after the timeout, so
+// that an abandoned load keeps its limiter slot while it runs
+func (c *LoadableCache[T, K]) loadWithTimeout(ctx context.Context, fn LoadFunctionCtx[T, K], arg T, release func()) (value K, err error) {
+	if c.config.LoadTimeout <= 0 {
+		defer release()
+		return c.loadWithRetry(ctx, fn, arg)
+	}
+
+	ctx, cancel := context.WithTimeoutCause(ctx, c.config.LoadTimeout, ErrLoadTimeout)
+	defer cancel()
+
+	type result struct {
+		value K
+		err   error
+	}
+
+	// the load function may ignore the context, so it runs aside and is
+	// abandoned when the timeout expires
+	ch := make(chan result, 1)
+	go func() {
+		defer release()
+
+		value, err := c.loadWithRetry(ctx, fn, arg)
+		ch <- result{value: value, err: err}
+	}()
+
+	select {
+	case r := <-ch:
+		// the load function reports the load timeout as the deadline of
+		// its context, which would be taken for the caller's deadline
+		if errors.Is(r.err, context.DeadlineExceeded) && context.Cause(ctx) == ErrLoadTimeout {
+			return r.value, ErrLoadTimeout
+		}
+		return r.value, r.err
+	case <-ctx.Done():
+		return value, context.Cause(ctx)
+	}
+}
+
+// loadWithRetry calls the load function according to the retry policy
+func (c *LoadableCache[T, K]) loadWithRetry(ctx context.Context, fn LoadFunctionCtx[T, K], arg T) (value K, err error) {
+	if c.config.Retry == nil {
+		return callLoadFunction(ctx, fn, arg)
+	}
+
+	err = c.config.Retry.retry(ctx, func(ctx context.Context) error {
+		value, err = callLoadFunction(ctx, fn, arg)
+		return err
+	})
+
+	return value, err
+}
+
+// loadFallback returns the stale value of a load rejected by the circuit
+// breaker or the fallback value of a failed load, or the load error if there
+// is none of them
+func (c *LoadableCache[T, K]) loadFallback(ctx context.Context, key string, arg T, err error) (K, error) {
+	if c.staleCache != nil && errors.Is(err, ErrCircuitOpen) {
+		value, staleErr := c.staleCache.Get(ctx, key)
+		if staleErr == nil {
+			return value, nil
+		}
+	}
+
+	if c.fallback == nil {
+		var zero K
+		return zero, err
+	}
+
+	value, err := c.fallback(ctx, arg, err)
+	if err != nil {
+		return value, err
+	}
+
+	if c.fallbackCache != nil {
+		c.fallbackCache.Set(ctx, key, value)
+	}
+
+	return value, nil
+}
+
+// callLoadFunction calls the load function, converting a panic to an error
+func callLoadFunction[T, K any](ctx context.Context, fn LoadFunctionCtx[T, K], arg T) (value K, err error) {
+	defer func() {
+		if err1 := recover(); err1 != nil {
+			err = newPanicError(err1)
+		}
+	}()
+
+	return fn(ctx, arg)
+}
@@ -2,6 +2,8 @@ package gocache
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"strings"
 	"sync"
 	"testing"
@@ -361,6 +363,121 @@ func TestLoadableCache_Delete(t *testing.T) {
 	}
 }
 
+func TestLoadableCache_ShareWindowInvalidation(t *testing.T) {
+	ctx := context.Background()
+	ms := NewMemoryCache[int](time.Minute)
+
+	var calls int
+	load := func(ctx context.Context, arg string) (int, error) {
+		calls++
+		return calls, nil
+	}
+	save := func(ctx context.Context, arg string, value int) error {
+		return nil
+	}
+
+	lc := NewLoadableCache[string, int](ms, WithShareWindow(time.Minute), WithSaveFunction(SaveFunction[string, int](save)))
+
+	if got, err := lc.LoadCtx(ctx, load, "k1"); err != nil || got != 1 {
+		t.Errorf("LoadableCache.LoadCtx() got = %v, error = %v, want = %v", got, err, 1)
+	}
+
+	// the result shared within the window must not outlive Delete
+	if err := lc.Delete(ctx, "k1"); err != nil {
+		t.Errorf("LoadableCache.Delete() error = %v", err)
+	}
+	if got, err := lc.LoadCtx(ctx, load, "k1"); err != nil || got != 2 {
+		t.Errorf("LoadableCache.LoadCtx() after Delete got = %v, error = %v, want = %v", got, err, 2)
+	}
+
+	// nor a Store followed by the expiration of the stored value
+	if err := lc.Store(ctx, "k1", 10); err != nil {
+		t.Errorf("LoadableCache.Store() error = %v", err)
+	}
+	ms.Delete(ctx, "k1")
+	if got, err := lc.LoadCtx(ctx, load, "k1"); err != nil || got != 3 {
+		t.Errorf("LoadableCache.LoadCtx() after Store got = %v, error = %v, want = %v", got, err, 3)
+	}
+}
+
+func TestLoadableCache_LoadTimeout(t *testing.T) {
+	ctx := context.Background()
+	lc := NewLoadableCache[string, string](NewMemoryCache[string](time.Minute), WithLoadTimeout(50*time.Millisecond))
+
+	// the load function ignores its context
+	slow := func(ctx context.Context, arg string) (string, error) {
+		time.Sleep(time.Second)
+		return "v1", nil
+	}
+
+	start := time.Now()
+	_, err := lc.LoadCtx(ctx, slow, "k1")
+	if err != ErrLoadTimeout {
+		t.Errorf("LoadableCache.LoadCtx() error = %v, want = %v", err, ErrLoadTimeout)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("LoadableCache.LoadCtx() returned after %v, want about 50ms", elapsed)
+	}
+}
+
+func TestLoadableCache_Fallback(t *testing.T) {
+	ctx := context.Background()
+	errBackend := errors.New("backend unavailable")
+
+	var fallbackErr error
+	lc := NewLoadableCache[string, string](NewMemoryCache[string](time.Minute),
+		WithFallback(func(ctx context.Context, arg string, err error) (string, error) {
+			fallbackErr = err
+			return "default", nil
+		}),
+		WithFallbackTTL(time.Minute),
+	)
+
+	var calls int
+	failing := func(ctx context.Context, arg string) (string, error) {
+		calls++
+		return "", errBackend
+	}
+
+	got, err := lc.LoadCtx(ctx, failing, "k1")
+	if err != nil || got != "default" {
+		t.Errorf("LoadableCache.LoadCtx() got = %v, error = %v, want = %v", got, err, "default")
+	}
+	if fallbackErr != errBackend {
+		t.Errorf("fallback error = %v, want = %v", fallbackErr, errBackend)
+	}
+
+	// the fallback value is cached, the backend is not called again
+	got, err = lc.LoadCtx(ctx, failing, "k1")
+	if err != nil || got != "default" || calls != 1 {
+		t.Errorf("LoadableCache.LoadCtx() got = %v, error = %v, calls = %v", got, err, calls)
+	}
+
+	// Delete drops the fallback value as well
+	if err := lc.Delete(ctx, "k1"); err != nil {
+		t.Errorf("LoadableCache.Delete() error = %v", err)
+	}
+
+	got, err = lc.LoadCtx(ctx, func(ctx context.Context, arg string) (string, error) {
+		return "v1", nil
+	}, "k1")
+	if err != nil || got != "v1" {
+		t.Errorf("LoadableCache.LoadCtx() got = %v, error = %v, want = %v", got, err, "v1")
+	}
+}
+
+func TestNewLoadableCache_FallbackTypeMismatch(t *testing.T) {
+	defer func() {
+		if r := recover(); r == nil {
+			t.Errorf("NewLoadableCache() with mismatched fallback expected panic, got nil")
+		}
+	}()
+
+	NewLoadableCache[string, string](NewMemoryCache[string](time.Minute), WithFallback(func(ctx context.Context, arg int, err error) (string, error) {
+		return "", nil
+	}))
+}
+
 func BenchmarkLoadableCache_GetObject(b *testing.B) {
 	client := redis.NewClient(&redis.Options{
 		Addr: "127.0.0.1:6379",
@@ -378,3 +495,73 @@ func BenchmarkLoadableCache_GetObject(b *testing.B) {
 		lc.Load(s.Get, request)
 	}
 }
+
+func TestLoadableCache_WithKeyFunc(t *testing.T) {
+	ctx := context.Background()
+	ms := NewMemoryCache[string](time.Minute)
+
+	type user struct {
+		ID     int64
+		Locale string
+	}
+
+	lc := NewLoadableCache[*user, string](ms, WithKeyFunc(KeyTemplate[*user]("user:{ID}:{Locale}")))
+
+	got, err := lc.LoadCtx(ctx, func(ctx context.Context, arg *user) (string, error) {
+		return fmt.Sprintf("%d-%s", arg.ID, arg.Locale), nil
+	}, &user{ID: 7, Locale: "en"})
+	if err != nil || got != "7-en" {
+		t.Errorf("LoadableCache.LoadCtx() got = %v, error = %v, want = %v", got, err, "7-en")
+	}
+
+	// the value is stored under the readable key
+	if got, err := ms.Get(ctx, "user:7:en"); err != nil || got != "7-en" {
+		t.Errorf("MemoryCache.Get() got = %v, error = %v, want = %v", got, err, "7-en")
+	}
+
+	if err := lc.Delete(ctx, &user{ID: 7, Locale: "en"}); err != nil {
+		t.Errorf("LoadableCache.Delete() error = %v", err)
+	}
+	if _, err := ms.Get(ctx, "user:7:en"); err != ErrRecordNotFound {
+		t.Errorf("MemoryCache.Get() after delete error = %v, want = %v", err, ErrRecordNotFound)
+	}
+}
+
+func TestLoadableCache_WithKeyEncoder(t *testing.T) {
+	ctx := context.Background()
+	ms := NewMemoryCache[string](time.Minute)
+
+	type user struct {
+		ID int64
+	}
+
+	encoder := NewKeyEncoder(WithKeyHash(XXHashKeyHash))
+	lc := NewLoadableCache[*user, string](ms, WithKeyEncoder(encoder))
+
+	got, err := lc.LoadCtx(ctx, func(ctx context.Context, arg *user) (string, error) {
+		return fmt.Sprint(arg.ID), nil
+	}, &user{ID: 7})
+	if err != nil || got != "7" {
+		t.Errorf("LoadableCache.LoadCtx() got = %v, error = %v, want = %v", got, err, "7")
+	}
+
+	// the value is stored under the key of the encoder, not the default one
+	if got, err := ms.Get(ctx, encoder.Key(&user{ID: 7})); err != nil || got != "7" {
+		t.Errorf("MemoryCache.Get() got = %v, error = %v, want = %v", got, err, "7")
+	}
+	if _, err := ms.Get(ctx, GenerateCacheKey(&user{ID: 7})); err != ErrRecordNotFound {
+		t.Errorf("MemoryCache.Get() with the default key error = %v, want = %v", err, ErrRecordNotFound)
+	}
+}
+
+func TestNewLoadableCache_KeyFuncTypeMismatch(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("NewLoadableCache() with a mismatched key function should panic")
+		}
+	}()
+
+	NewLoadableCache[string, string](NewMemoryCache[string](time.Minute), WithKeyFunc(func(arg int) string {
+		return ""
+	}))
+}
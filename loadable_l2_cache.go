@@ -10,17 +10,26 @@ type LoadableL2Cache[T, K any] struct {
 	*LoadableCache[T, K]
 }
 
-func NewLoadableL2Cache[T, K any](client *redis.Client, expiration time.Duration) *LoadableL2Cache[T, K] {
+func NewLoadableL2Cache[T, K any](client *redis.Client, expiration time.Duration, options ...LoadOption) *LoadableL2Cache[T, K] {
+	return NewLoadableL2CacheWithSingleFlight[T, K](client, expiration, NewSingleFlight[T, K](options...), options...)
+}
+
+// NewLoadableL2CacheWithSingleFlight instantiates a new L2 cache that uses the
+// given single flight to deduplicate the loads, e.g. a DistributedSingleFlight
+// to deduplicate them across instances
+func NewLoadableL2CacheWithSingleFlight[T, K any](client *redis.Client, expiration time.Duration, singleFlight SingleFlight[T, K], options ...LoadOption) *LoadableL2Cache[T, K] {
 	if expiration <= 0 {
 		panic("gocache: NewLoadableL2Cache expiration must be positive")
 	}
 
 	return &LoadableL2Cache[T, K]{
-		NewLoadableCache[T, K](
+		NewLoadableCacheWithSingleFlight[T, K](
 			NewChainCache[K](
 				NewMemoryCache[K](expiration/4),      // L1 cache
 				NewRedisCache[K](client, expiration), // L2 cache
 			),
+			singleFlight,
+			options...,
 		),
 	}
 }
@@ -1,6 +1,9 @@
 package gocache
 
 import (
+	"context"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -17,6 +20,49 @@ func TestNewLoadableL2Cache_InvalidExpiration(t *testing.T) {
 	NewLoadableL2Cache[*getRequest, *getResponse](nil, 0)
 }
 
+func TestLoadableL2CacheWithSingleFlight(t *testing.T) {
+	client := requireRedis(t)
+	ctx := context.Background()
+
+	// each cache stands for a separate instance, sharing the redis cache and
+	// the flights
+	instances := make([]*LoadableL2Cache[*addRequest, *addResponse], 3)
+	for index := range instances {
+		sf := NewDistributedSingleFlight[*addRequest, *addResponse](client, withTestFlightPrefix(t))
+		instances[index] = NewLoadableL2CacheWithSingleFlight[*addRequest, *addResponse](client, time.Minute, sf)
+	}
+
+	// a fresh argument, so that no earlier run has cached it
+	request := &addRequest{Value: time.Now().UnixNano()}
+	defer instances[0].Delete(ctx, request)
+
+	var calls int64
+	load := func(ctx context.Context, request *addRequest) (*addResponse, error) {
+		atomic.AddInt64(&calls, 1)
+		time.Sleep(100 * time.Millisecond)
+		return &addResponse{Total: request.Value}, nil
+	}
+
+	wg := &sync.WaitGroup{}
+	for index := 0; index < 30; index++ {
+		wg.Add(1)
+		go func(lc *LoadableL2Cache[*addRequest, *addResponse]) {
+			defer wg.Done()
+
+			response, err := lc.LoadCtx(ctx, load, request)
+			if err != nil || response.Total != request.Value {
+				t.Errorf("LoadableL2Cache.LoadCtx() got = %v, error = %v, want = %v", response, err, request.Value)
+			}
+		}(instances[index%len(instances)])
+	}
+
+	wg.Wait()
+
+	if calls != 1 {
+		t.Errorf("LoadableL2Cache.LoadCtx() called the function %d times, want %d", calls, 1)
+	}
+}
+
 func BenchmarkLoadableL2Cache_GetObject(b *testing.B) {
 	client := redis.NewClient(&redis.Options{
 		Addr: "127.0.0.1:6379",
@@ -0,0 +1,158 @@
+package gocache
+
+import (
+	"context"
+	"crypto/md5"
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+const (
+	// memcachedMaxKeyLength is the longest key memcached accepts
+	memcachedMaxKeyLength = 250
+	// memcachedMaxRelativeExpiration is the longest expiration memcached
+	// accepts in seconds, longer ones are taken as unix timestamps
+	memcachedMaxRelativeExpiration = 30 * 24 * 60 * 60
+)
+
+type MemcachedCache[T any] struct {
+	config          *CacheConfig
+	client          *memcache.Client
+	expiration      time.Duration
+	expiryDeviation float64
+}
+
+func NewMemcachedCache[T any](client *memcache.Client, expiration time.Duration, options ...CacheOption) *MemcachedCache[T] {
+	if expiration <= 0 {
+		panic("gocache: NewMemcachedCache expiration must be positive")
+	}
+
+	s := &MemcachedCache[T]{
+		config:          &CacheConfig{},
+		client:          client,
+		expiration:      expiration,
+		expiryDeviation: ExpiryDeviation,
+	}
+
+	for _, option := range options {
+		option(s.config)
+	}
+
+	return s
+}
+
+func (s MemcachedCache[T]) Set(ctx context.Context, key string, value T) error {
+	marshaled, err := s.config.codec().Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	return backendError(s.client.Set(&memcache.Item{
+		Key:        s.key(key),
+		Value:      marshaled,
+		Expiration: s.randomExpiration(),
+	}))
+}
+
+func (s MemcachedCache[T]) Get(ctx context.Context, key string) (value T, err error) {
+	item, err := s.client.Get(s.key(key))
+	if err == memcache.ErrCacheMiss {
+		return value, ErrRecordNotFound
+	}
+	if err != nil {
+		return value, backendError(err)
+	}
+
+	err = s.config.codec().Unmarshal(item.Value, &value)
+	if err != nil {
+		return value, err
+	}
+
+	return value, nil
+}
+
+func (s MemcachedCache[T]) Delete(ctx context.Context, key string) error {
+	err := s.client.Delete(s.key(key))
+	if err == memcache.ErrCacheMiss {
+		return nil
+	}
+
+	return backendError(err)
+}
+
+// GetMulti gets the values of the keys in one round trip per server, missing
+// keys are left out of the result
+func (s MemcachedCache[T]) GetMulti(ctx context.Context, keys []string) (map[string]T, error) {
+	originals := make(map[string]string, len(keys))
+	memcachedKeys := make([]string, 0, len(keys))
+	for _, key := range keys {
+		memcachedKey := s.key(key)
+		originals[memcachedKey] = key
+		memcachedKeys = append(memcachedKeys, memcachedKey)
+	}
+
+	items, err := s.client.GetMulti(memcachedKeys)
+	if err != nil {
+		return nil, backendError(err)
+	}
+
+	values := make(map[string]T, len(items))
+	for memcachedKey, item := range items {
+		var value T
+		err = s.config.codec().Unmarshal(item.Value, &value)
+		if err != nil {
+			return nil, err
+		}
+
+		values[originals[memcachedKey]] = value
+	}
+
+	return values, nil
+}
+
+// key returns the configured key made acceptable to memcached: whitespace,
+// control characters and '%' are escaped, and keys longer than 250 bytes are
+// shortened and suffixed with the md5 of the whole key
+func (s MemcachedCache[T]) key(key string) string {
+	key = s.config.key(key)
+
+	if strings.IndexFunc(key, func(r rune) bool { return r <= ' ' || r == 0x7f || r == '%' }) >= 0 {
+		var builder strings.Builder
+		for i := 0; i < len(key); i++ {
+			if c := key[i]; c <= ' ' || c == 0x7f || c == '%' {
+				fmt.Fprintf(&builder, "%%%02X", c)
+			} else {
+				builder.WriteByte(c)
+			}
+		}
+		key = builder.String()
+	}
+
+	if len(key) > memcachedMaxKeyLength {
+		hash := fmt.Sprintf("%x", md5.Sum([]byte(key)))
+		key = key[:memcachedMaxKeyLength-len(hash)-1] + ":" + hash
+	}
+
+	return key
+}
+
+// randomExpiration returns the expiration randomized by expiryDeviation in
+// the seconds memcached expects
+func (s MemcachedCache[T]) randomExpiration() int32 {
+	// interval [0.95, 1.05)
+	deviation := 1.0 - s.expiryDeviation + rand.Float64()*s.expiryDeviation*2
+	expiration := time.Duration(float64(s.expiration) * deviation)
+
+	// 0 means never expire, so round up to at least one second
+	seconds := int64(math.Ceil(expiration.Seconds()))
+	if seconds > memcachedMaxRelativeExpiration {
+		return int32(time.Now().Add(expiration).Unix())
+	}
+
+	return int32(seconds)
+}
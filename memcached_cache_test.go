@@ -0,0 +1,217 @@
+package gocache
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+// fakeMemcached is an in-process server speaking the subset of the memcached
+// text protocol used by MemcachedCache
+type fakeMemcached struct {
+	listener net.Listener
+
+	lock        sync.Mutex
+	values      map[string][]byte
+	expirations map[string]int32
+}
+
+func newFakeMemcached(t *testing.T) *fakeMemcached {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("net.Listen() error = %v", err)
+	}
+
+	s := &fakeMemcached{
+		listener:    listener,
+		values:      make(map[string][]byte),
+		expirations: make(map[string]int32),
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+
+	return s
+}
+
+func (s *fakeMemcached) serve(conn net.Conn) {
+	defer conn.Close()
+
+	rw := bufio.NewReadWriter(bufio.NewReader(conn), bufio.NewWriter(conn))
+	for {
+		line, err := rw.ReadString('\n')
+		if err != nil {
+			return
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			return
+		}
+
+		s.lock.Lock()
+		switch fields[0] {
+		case "gets", "get":
+			for _, key := range fields[1:] {
+				if value, found := s.values[key]; found {
+					fmt.Fprintf(rw, "VALUE %s 0 %d 1\r\n%s\r\n", key, len(value), value)
+				}
+			}
+			fmt.Fprint(rw, "END\r\n")
+		case "set":
+			expiration, _ := strconv.Atoi(fields[3])
+			length, _ := strconv.Atoi(fields[4])
+			value := make([]byte, length+2)
+			if _, err := io.ReadFull(rw, value); err != nil {
+				s.lock.Unlock()
+				return
+			}
+			s.values[fields[1]] = value[:length]
+			s.expirations[fields[1]] = int32(expiration)
+			fmt.Fprint(rw, "STORED\r\n")
+		case "delete":
+			if _, found := s.values[fields[1]]; found {
+				delete(s.values, fields[1])
+				fmt.Fprint(rw, "DELETED\r\n")
+			} else {
+				fmt.Fprint(rw, "NOT_FOUND\r\n")
+			}
+		default:
+			fmt.Fprint(rw, "ERROR\r\n")
+		}
+		s.lock.Unlock()
+
+		if err := rw.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+func (s *fakeMemcached) keys() []string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	keys := make([]string, 0, len(s.values))
+	for key := range s.values {
+		keys = append(keys, key)
+	}
+
+	return keys
+}
+
+func TestMemcachedCache_SetGetDelete(t *testing.T) {
+	ctx := context.Background()
+	server := newFakeMemcached(t)
+	client := memcache.New(server.listener.Addr().String())
+
+	mc := NewMemcachedCache[*getResponse](client, time.Minute, WithKeyPrefix("memcached:"))
+
+	if _, err := mc.Get(ctx, "k1"); err != ErrRecordNotFound {
+		t.Errorf("MemcachedCache.Get() error = %v, want = %v", err, ErrRecordNotFound)
+	}
+
+	if err := mc.Set(ctx, "k1", &getResponse{Value: 1}); err != nil {
+		t.Fatalf("MemcachedCache.Set() error = %v", err)
+	}
+
+	got, err := mc.Get(ctx, "k1")
+	if err != nil || got.Value != 1 {
+		t.Errorf("MemcachedCache.Get() got = %v, error = %v, want = %v", got, err, 1)
+	}
+
+	// jittered around the expiration in seconds
+	server.lock.Lock()
+	expiration := server.expirations["memcached:k1"]
+	server.lock.Unlock()
+	if expiration < 57 || expiration > 63 {
+		t.Errorf("MemcachedCache.Set() expiration got = %v, want = %v", expiration, 60)
+	}
+
+	if err := mc.Delete(ctx, "k1"); err != nil {
+		t.Errorf("MemcachedCache.Delete() error = %v", err)
+	}
+	if err := mc.Delete(ctx, "k1"); err != nil {
+		t.Errorf("MemcachedCache.Delete() missing key error = %v", err)
+	}
+
+	if _, err := mc.Get(ctx, "k1"); err != ErrRecordNotFound {
+		t.Errorf("MemcachedCache.Get() after delete error = %v, want = %v", err, ErrRecordNotFound)
+	}
+}
+
+func TestMemcachedCache_Key(t *testing.T) {
+	ctx := context.Background()
+	server := newFakeMemcached(t)
+	client := memcache.New(server.listener.Addr().String())
+
+	mc := NewMemcachedCache[string](client, time.Minute)
+
+	tests := []struct {
+		name string
+		key  string
+	}{
+		{"whitespace", "user name\tline\n"},
+		{"percent", "100%"},
+		{"long", strings.Repeat("k", 300)},
+		{"long escaped", strings.Repeat(" ", 100)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := mc.Set(ctx, tt.key, tt.name); err != nil {
+				t.Fatalf("MemcachedCache.Set() error = %v", err)
+			}
+
+			got, err := mc.Get(ctx, tt.key)
+			if err != nil || got != tt.name {
+				t.Errorf("MemcachedCache.Get() got = %v, error = %v, want = %v", got, err, tt.name)
+			}
+		})
+	}
+
+	// escaping keeps distinct keys distinct
+	if mc.key("a b") == mc.key("a%20b") {
+		t.Errorf("MemcachedCache.key() got the same key for %q and %q", "a b", "a%20b")
+	}
+
+	for _, key := range server.keys() {
+		if len(key) > memcachedMaxKeyLength {
+			t.Errorf("MemcachedCache.key() length got = %v, want <= %v", len(key), memcachedMaxKeyLength)
+		}
+	}
+}
+
+func TestMemcachedCache_GetMulti(t *testing.T) {
+	ctx := context.Background()
+	server := newFakeMemcached(t)
+	client := memcache.New(server.listener.Addr().String())
+
+	mc := NewMemcachedCache[int](client, time.Minute, WithKeyPrefix("multi:"))
+	mc.Set(ctx, "k1", 1)
+	mc.Set(ctx, "k 2", 2)
+
+	got, err := mc.GetMulti(ctx, []string{"k1", "k 2", "k3"})
+	if err != nil {
+		t.Fatalf("MemcachedCache.GetMulti() error = %v", err)
+	}
+
+	if len(got) != 2 || got["k1"] != 1 || got["k 2"] != 2 {
+		t.Errorf("MemcachedCache.GetMulti() got = %v, want = %v", got, map[string]int{"k1": 1, "k 2": 2})
+	}
+}
@@ -0,0 +1,53 @@
+package gocache
+
+import "context"
+
+// Tuple2 is the argument of a function memoized by Memoize2
+type Tuple2[A, B any] struct {
+	V1 A
+	V2 B
+}
+
+// Tuple3 is the argument of a function memoized by Memoize3
+type Tuple3[A, B, C any] struct {
+	V1 A
+	V2 B
+	V3 C
+}
+
+// Memoize returns fn wrapped in a LoadableCache, so that its results are
+// cached by argument. Functions memoized on the same cache with the same
+// argument type share keys, use a key prefix on the cache to separate them.
+func Memoize[T, K any](cache Cache[K], fn LoadFunctionCtx[T, K], options ...LoadOption) func(context.Context, T) (K, error) {
+	lc := NewLoadableCache[T](cache, options...)
+
+	return func(ctx context.Context, arg T) (K, error) {
+		return lc.LoadCtx(ctx, fn, arg)
+	}
+}
+
+// Memoize2 is like Memoize for functions with two arguments, keyed on a
+// Tuple2 of them. Generic options such as WithFallback take the Tuple2 as
+// argument type.
+func Memoize2[A, B, K any](cache Cache[K], fn func(context.Context, A, B) (K, error), options ...LoadOption) func(context.Context, A, B) (K, error) {
+	memoized := Memoize(cache, func(ctx context.Context, arg Tuple2[A, B]) (K, error) {
+		return fn(ctx, arg.V1, arg.V2)
+	}, options...)
+
+	return func(ctx context.Context, a A, b B) (K, error) {
+		return memoized(ctx, Tuple2[A, B]{V1: a, V2: b})
+	}
+}
+
+// Memoize3 is like Memoize for functions with three arguments, keyed on a
+// Tuple3 of them. Generic options such as WithFallback take the Tuple3 as
+// argument type.
+func Memoize3[A, B, C, K any](cache Cache[K], fn func(context.Context, A, B, C) (K, error), options ...LoadOption) func(context.Context, A, B, C) (K, error) {
+	memoized := Memoize(cache, func(ctx context.Context, arg Tuple3[A, B, C]) (K, error) {
+		return fn(ctx, arg.V1, arg.V2, arg.V3)
+	}, options...)
+
+	return func(ctx context.Context, a A, b B, c C) (K, error) {
+		return memoized(ctx, Tuple3[A, B, C]{V1: a, V2: b, V3: c})
+	}
+}
@@ -0,0 +1,95 @@
+package gocache
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMemoize(t *testing.T) {
+	ctx := context.Background()
+	var calls atomic.Int64
+
+	square := Memoize(NewMemoryCache[int](time.Minute), func(ctx context.Context, arg int) (int, error) {
+		calls.Add(1)
+		return arg * arg, nil
+	})
+
+	tests := []struct {
+		arg       int
+		want      int
+		wantCalls int64
+	}{
+		{2, 4, 1},
+		{2, 4, 1},
+		{3, 9, 2},
+	}
+	for _, tt := range tests {
+		got, err := square(ctx, tt.arg)
+		if err != nil || got != tt.want {
+			t.Errorf("Memoize() got = %v, error = %v, want = %v", got, err, tt.want)
+		}
+		if got := calls.Load(); got != tt.wantCalls {
+			t.Errorf("Memoize() calls got = %v, want = %v", got, tt.wantCalls)
+		}
+	}
+}
+
+func TestMemoize2(t *testing.T) {
+	ctx := context.Background()
+	var calls atomic.Int64
+
+	join := Memoize2(NewMemoryCache[string](time.Minute), func(ctx context.Context, a string, b int) (string, error) {
+		calls.Add(1)
+		return fmt.Sprintf("%s-%d", a, b), nil
+	})
+
+	tests := []struct {
+		a         string
+		b         int
+		want      string
+		wantCalls int64
+	}{
+		{"a", 1, "a-1", 1},
+		{"a", 1, "a-1", 1},
+		{"a", 2, "a-2", 2},
+		{"b", 1, "b-1", 3},
+	}
+	for _, tt := range tests {
+		got, err := join(ctx, tt.a, tt.b)
+		if err != nil || got != tt.want {
+			t.Errorf("Memoize2() got = %v, error = %v, want = %v", got, err, tt.want)
+		}
+		if got := calls.Load(); got != tt.wantCalls {
+			t.Errorf("Memoize2() calls got = %v, want = %v", got, tt.wantCalls)
+		}
+	}
+}
+
+func TestMemoize3(t *testing.T) {
+	ctx := context.Background()
+	var calls atomic.Int64
+
+	sum := Memoize3(NewMemoryCache[int](time.Minute), func(ctx context.Context, a, b, c int) (int, error) {
+		calls.Add(1)
+		if a < 0 {
+			return 0, fmt.Errorf("negative argument %d", a)
+		}
+		return a + b + c, nil
+	}, WithFallback(func(ctx context.Context, arg Tuple3[int, int, int], err error) (int, error) {
+		return -1, nil
+	}))
+
+	if got, err := sum(ctx, 1, 2, 3); err != nil || got != 6 {
+		t.Errorf("Memoize3() got = %v, error = %v, want = %v", got, err, 6)
+	}
+	if got, err := sum(ctx, 1, 2, 3); err != nil || got != 6 || calls.Load() != 1 {
+		t.Errorf("Memoize3() got = %v, error = %v, calls = %v, want = %v", got, err, calls.Load(), 6)
+	}
+
+	if got, err := sum(ctx, -1, 2, 3); err != nil || got != -1 {
+		t.Errorf("Memoize3() fallback got = %v, error = %v, want = %v", got, err, -1)
+	}
+}
@@ -3,6 +3,7 @@ package gocache
 import (
 	"context"
 	"math/rand"
+	"reflect"
 	"sync"
 	"time"
 
@@ -13,8 +14,9 @@ import (
 // every Set of the key, so an expired timer callback can tell whether the
 // entry it observed is still the current one before deleting it.
 type entry[T any] struct {
-	value T
-	gen   uint64
+	value    T
+	gen      uint64
+	expireAt time.Time
 }
 
 type MemoryCache[T any] struct {
@@ -69,39 +71,22 @@ func NewMemoryCache[T any](expiration time.Duration, options ...CacheOption) *Me
 }
 
 func (s *MemoryCache[T]) Set(ctx context.Context, key string, value T) error {
-	if s.config.Prefix != "" {
-		key = s.config.Prefix + key
-	}
-
-	// interval [0.95, 1.05)
-	deviation := 1.0 - s.expiryDeviation + rand.Float64()*s.expiryDeviation*2
-	expiration := time.Duration(float64(s.expiration) * deviation)
+	key = s.config.key(key)
 
 	s.lock.Lock()
-	e, found := s.data[key]
-	if !found {
-		e = &entry[T]{}
-		s.data[key] = e
-	}
-	e.value = value
-	e.gen = s.nextGen()
-	// update the timing wheel while holding the data lock, so that Set/Delete
-	// and the expiry callback can never interleave
-	s.timingWheel.Set(key, e.gen, expiration)
+	s.set(key, value)
 	s.lock.Unlock()
 
 	return nil
 }
 
 func (s *MemoryCache[T]) Get(ctx context.Context, key string) (T, error) {
-	if s.config.Prefix != "" {
-		key = s.config.Prefix + key
-	}
+	key = s.config.key(key)
 
 	s.lock.Lock()
 	defer s.lock.Unlock()
 
-	e, ok := s.data[key]
+	e, ok := s.lookup(key)
 	if ok {
 		return e.value, nil
 	}
@@ -111,9 +96,7 @@ func (s *MemoryCache[T]) Get(ctx context.Context, key string) (T, error) {
 }
 
 func (s *MemoryCache[T]) Delete(ctx context.Context, key string) error {
-	if s.config.Prefix != "" {
-		key = s.config.Prefix + key
-	}
+	key = s.config.key(key)
 
 	s.lock.Lock()
 	delete(s.data, key)
@@ -123,6 +106,159 @@ func (s *MemoryCache[T]) Delete(ctx context.Context, key string) error {
 	return nil
 }
 
+// GetWithTTL returns the value of the key along with its remaining lifetime
+func (s *MemoryCache[T]) GetWithTTL(ctx context.Context, key string) (T, time.Duration, error) {
+	key = s.config.key(key)
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	e, ok := s.lookup(key)
+	if ok {
+		if ttl := time.Until(e.expireAt); ttl > 0 {
+			return e.value, ttl, nil
+		}
+	}
+
+	var zero T
+	return zero, 0, ErrRecordNotFound
+}
+
+// SetWithTTL sets the value to expire after ttl, capped by the expiration of
+// the cache
+func (s *MemoryCache[T]) SetWithTTL(ctx context.Context, key string, value T, ttl time.Duration) error {
+	key = s.config.key(key)
+
+	s.lock.Lock()
+	if ttl > 0 && ttl < s.expiration {
+		s.setWithExpiration(key, value, ttl)
+	} else {
+		s.set(key, value)
+	}
+	s.lock.Unlock()
+
+	return nil
+}
+
+// SetNX sets the value only if the key does not exist
+func (s *MemoryCache[T]) SetNX(ctx context.Context, key string, value T) (bool, error) {
+	key = s.config.key(key)
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if _, found := s.lookup(key); found {
+		return false, nil
+	}
+
+	s.set(key, value)
+	return true, nil
+}
+
+// GetOrSet returns the existing value of the key, or sets the given value if
+// the key does not exist
+func (s *MemoryCache[T]) GetOrSet(ctx context.Context, key string, value T) (T, bool, error) {
+	key = s.config.key(key)
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if e, found := s.lookup(key); found {
+		return e.value, true, nil
+	}
+
+	s.set(key, value)
+	return value, false, nil
+}
+
+// CompareAndSwap sets the key to new only if its current value deeply equals
+// old, a missing key never matches
+func (s *MemoryCache[T]) CompareAndSwap(ctx context.Context, key string, old, new T) (bool, error) {
+	key = s.config.key(key)
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	e, found := s.lookup(key)
+	if !found || !reflect.DeepEqual(e.value, old) {
+		return false, nil
+	}
+
+	s.set(key, new)
+	return true, nil
+}
+
+// GetVersioned returns the value of the key along with its version, the
+// version is the generation number of the entry
+func (s *MemoryCache[T]) GetVersioned(ctx context.Context, key string) (T, uint64, error) {
+	key = s.config.key(key)
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	e, ok := s.lookup(key)
+	if ok {
+		return e.value, e.gen, nil
+	}
+
+	var zero T
+	return zero, 0, ErrRecordNotFound
+}
+
+// SetIfVersion sets the value only if the current version of the key equals
+// version, version 0 matches a missing key
+func (s *MemoryCache[T]) SetIfVersion(ctx context.Context, key string, value T, version uint64) (bool, error) {
+	key = s.config.key(key)
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	var current uint64
+	if e, found := s.lookup(key); found {
+		current = e.gen
+	}
+	if current != version {
+		return false, nil
+	}
+
+	s.set(key, value)
+	return true, nil
+}
+
+// lookup returns the entry of the key unless it has expired, the timing wheel
+// may not have removed an expired entry yet. s.lock must be held
+func (s *MemoryCache[T]) lookup(key string) (*entry[T], bool) {
+	e, found := s.data[key]
+	if !found || !time.Now().Before(e.expireAt) {
+		return nil, false
+	}
+
+	return e, true
+}
+
+// set stores the value and schedules its expiration, s.lock must be held
+func (s *MemoryCache[T]) set(key string, value T) {
+	// interval [0.95, 1.05)
+	deviation := 1.0 - s.expiryDeviation + rand.Float64()*s.expiryDeviation*2
+	s.setWithExpiration(key, value, time.Duration(float64(s.expiration)*deviation))
+}
+
+// setWithExpiration stores the value and schedules its expiration after the
+// given duration, s.lock must be held
+func (s *MemoryCache[T]) setWithExpiration(key string, value T, expiration time.Duration) {
+	e, found := s.data[key]
+	if !found {
+		e = &entry[T]{}
+		s.data[key] = e
+	}
+	e.value = value
+	e.gen = s.nextGen()
+	e.expireAt = time.Now().Add(expiration)
+	// update the timing wheel while holding the data lock, so that Set/Delete
+	// and the expiry callback can never interleave
+	s.timingWheel.Set(key, e.gen, expiration)
+}
+
 // nextGen returns a monotonically increasing generation number so that the
 // generation of a freshly created entry can never collide with a pending
 // timer scheduled for the same key before it was deleted.
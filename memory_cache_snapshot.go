@@ -0,0 +1,81 @@
+package gocache
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+)
+
+// snapshotRecord is a MemoryCache entry in a snapshot
+type snapshotRecord struct {
+	Key string `json:"key"`
+	// value encoded with the codec of the cache
+	Value []byte `json:"value"`
+	// expiration time in unix milliseconds
+	ExpireAt int64 `json:"expire_at"`
+}
+
+// SaveTo writes all live entries to w, one JSON record per line with the value
+// encoded by the configured codec and the time the entry expires. Keys are
+// written with the key prefix of the cache, so the snapshot must be restored
+// into a cache with the same prefix.
+func (s *MemoryCache[T]) SaveTo(w io.Writer) error {
+	s.lock.Lock()
+	records := make([]snapshotRecord, 0, len(s.data))
+	values := make([]T, 0, len(s.data))
+	for key, e := range s.data {
+		records = append(records, snapshotRecord{Key: key, ExpireAt: e.expireAt.UnixMilli()})
+		values = append(values, e.value)
+	}
+	s.lock.Unlock()
+
+	codec := s.config.codec()
+	encoder := json.NewEncoder(w)
+	for index := range records {
+		value, err := codec.Marshal(values[index])
+		if err != nil {
+			return err
+		}
+		records[index].Value = value
+
+		err = encoder.Encode(&records[index])
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// LoadFrom restores the entries written by SaveTo, each one expires at the
+// time it would have expired in the saved cache. Entries that expired in the
+// meantime are skipped and existing entries with the same key are replaced.
+func (s *MemoryCache[T]) LoadFrom(r io.Reader) error {
+	codec := s.config.codec()
+	decoder := json.NewDecoder(r)
+	for {
+		var record snapshotRecord
+		err := decoder.Decode(&record)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		expiration := time.Until(time.UnixMilli(record.ExpireAt))
+		if expiration <= 0 {
+			continue
+		}
+
+		var value T
+		err = codec.Unmarshal(record.Value, &value)
+		if err != nil {
+			return err
+		}
+
+		s.lock.Lock()
+		s.setWithExpiration(record.Key, value, expiration)
+		s.lock.Unlock()
+	}
+}
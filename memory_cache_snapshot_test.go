@@ -0,0 +1,69 @@
+package gocache
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemoryCache_SaveToAndLoadFrom(t *testing.T) {
+	ctx := context.Background()
+	ms := NewMemoryCache[*getResponse](time.Second, WithKeyPrefix("snapshot:"))
+
+	if err := ms.Set(ctx, "k1", &getResponse{Value: 1}); err != nil {
+		t.Errorf("MemoryCache.Set() error = %v", err)
+	}
+	if err := ms.Set(ctx, "k2", &getResponse{Value: 2}); err != nil {
+		t.Errorf("MemoryCache.Set() error = %v", err)
+	}
+
+	buffer := &bytes.Buffer{}
+	if err := ms.SaveTo(buffer); err != nil {
+		t.Fatalf("MemoryCache.SaveTo() error = %v", err)
+	}
+
+	time.Sleep(500 * time.Millisecond)
+
+	restored := NewMemoryCache[*getResponse](time.Minute, WithKeyPrefix("snapshot:"))
+	if err := restored.LoadFrom(buffer); err != nil {
+		t.Fatalf("MemoryCache.LoadFrom() error = %v", err)
+	}
+
+	for key, want := range map[string]int64{"k1": 1, "k2": 2} {
+		got, err := restored.Get(ctx, key)
+		if err != nil || got.Value != want {
+			t.Errorf("MemoryCache.Get() got = %v, error = %v, want = %v", got, err, want)
+		}
+	}
+
+	// the entries keep their remaining time to live instead of the
+	// expiration of the restored cache
+	time.Sleep(time.Second)
+
+	if _, err := restored.Get(ctx, "k1"); err != ErrRecordNotFound {
+		t.Errorf("MemoryCache.Get() after expiration error = %v, want = %v", err, ErrRecordNotFound)
+	}
+}
+
+func TestMemoryCache_LoadFrom_Expired(t *testing.T) {
+	ctx := context.Background()
+	ms := NewMemoryCache[string](100 * time.Millisecond)
+	ms.Set(ctx, "k1", "v1")
+
+	buffer := &bytes.Buffer{}
+	if err := ms.SaveTo(buffer); err != nil {
+		t.Fatalf("MemoryCache.SaveTo() error = %v", err)
+	}
+
+	time.Sleep(200 * time.Millisecond)
+
+	restored := NewMemoryCache[string](time.Minute)
+	if err := restored.LoadFrom(buffer); err != nil {
+		t.Fatalf("MemoryCache.LoadFrom() error = %v", err)
+	}
+
+	if _, err := restored.Get(ctx, "k1"); err != ErrRecordNotFound {
+		t.Errorf("MemoryCache.Get() error = %v, want = %v", err, ErrRecordNotFound)
+	}
+}
@@ -2,6 +2,9 @@ package gocache
 
 import (
 	"context"
+	"crypto/md5"
+	"fmt"
+	"strings"
 	"testing"
 	"time"
 )
@@ -97,6 +100,159 @@ func TestMemoryCache_WithKeyPrefix(t *testing.T) {
 	}
 }
 
+func TestMemoryCache_SetNX(t *testing.T) {
+	ctx := context.Background()
+	ms := NewMemoryCache[string](time.Minute)
+
+	set, err := ms.SetNX(ctx, "k1", "v1")
+	if err != nil || !set {
+		t.Errorf("MemoryCache.SetNX() got = %v, error = %v, want = %v", set, err, true)
+	}
+
+	set, err = ms.SetNX(ctx, "k1", "v2")
+	if err != nil || set {
+		t.Errorf("MemoryCache.SetNX() got = %v, error = %v, want = %v", set, err, false)
+	}
+
+	if got, _ := ms.Get(ctx, "k1"); got != "v1" {
+		t.Errorf("MemoryCache.Get() got = %v, want = %v", got, "v1")
+	}
+}
+
+func TestMemoryCache_GetOrSet(t *testing.T) {
+	ctx := context.Background()
+	ms := NewMemoryCache[string](time.Minute)
+
+	actual, loaded, err := ms.GetOrSet(ctx, "k1", "v1")
+	if err != nil || loaded || actual != "v1" {
+		t.Errorf("MemoryCache.GetOrSet() got = %v, loaded = %v, error = %v", actual, loaded, err)
+	}
+
+	actual, loaded, err = ms.GetOrSet(ctx, "k1", "v2")
+	if err != nil || !loaded || actual != "v1" {
+		t.Errorf("MemoryCache.GetOrSet() got = %v, loaded = %v, error = %v", actual, loaded, err)
+	}
+}
+
+func TestMemoryCache_CompareAndSwap(t *testing.T) {
+	ctx := context.Background()
+	ms := NewMemoryCache[*getResponse](time.Minute)
+
+	// a missing key never matches
+	swapped, err := ms.CompareAndSwap(ctx, "k1", nil, &getResponse{Value: 1})
+	if err != nil || swapped {
+		t.Errorf("MemoryCache.CompareAndSwap() got = %v, error = %v, want = %v", swapped, err, false)
+	}
+
+	if err := ms.Set(ctx, "k1", &getResponse{Value: 1}); err != nil {
+		t.Errorf("MemoryCache.Set() error = %v", err)
+	}
+
+	// values are compared deeply, not by pointer
+	swapped, err = ms.CompareAndSwap(ctx, "k1", &getResponse{Value: 1}, &getResponse{Value: 2})
+	if err != nil || !swapped {
+		t.Errorf("MemoryCache.CompareAndSwap() got = %v, error = %v, want = %v", swapped, err, true)
+	}
+
+	swapped, err = ms.CompareAndSwap(ctx, "k1", &getResponse{Value: 1}, &getResponse{Value: 3})
+	if err != nil || swapped {
+		t.Errorf("MemoryCache.CompareAndSwap() got = %v, error = %v, want = %v", swapped, err, false)
+	}
+
+	if got, _ := ms.Get(ctx, "k1"); got.Value != 2 {
+		t.Errorf("MemoryCache.Get() got = %v, want = %v", got.Value, 2)
+	}
+}
+
+func TestMemoryCache_SetIfVersion(t *testing.T) {
+	ctx := context.Background()
+	ms := NewMemoryCache[string](time.Minute)
+
+	// version 0 matches a missing key
+	set, err := ms.SetIfVersion(ctx, "k1", "v1", 0)
+	if err != nil || !set {
+		t.Errorf("MemoryCache.SetIfVersion() got = %v, error = %v, want = %v", set, err, true)
+	}
+
+	got, version, err := ms.GetVersioned(ctx, "k1")
+	if err != nil || got != "v1" || version == 0 {
+		t.Errorf("MemoryCache.GetVersioned() got = %v, version = %v, error = %v", got, version, err)
+	}
+
+	// a concurrent writer bumps the version
+	if err := ms.Set(ctx, "k1", "v2"); err != nil {
+		t.Errorf("MemoryCache.Set() error = %v", err)
+	}
+
+	set, err = ms.SetIfVersion(ctx, "k1", "v3", version)
+	if err != nil || set {
+		t.Errorf("MemoryCache.SetIfVersion() got = %v, error = %v, want = %v", set, err, false)
+	}
+
+	_, newVersion, _ := ms.GetVersioned(ctx, "k1")
+	if newVersion <= version {
+		t.Errorf("MemoryCache.GetVersioned() version = %v, want > %v", newVersion, version)
+	}
+
+	set, err = ms.SetIfVersion(ctx, "k1", "v3", newVersion)
+	if err != nil || !set {
+		t.Errorf("MemoryCache.SetIfVersion() got = %v, error = %v, want = %v", set, err, true)
+	}
+
+	if _, _, err := ms.GetVersioned(ctx, "k2"); err != ErrRecordNotFound {
+		t.Errorf("MemoryCache.GetVersioned() error = %v, want = %v", err, ErrRecordNotFound)
+	}
+}
+
+func TestMemoryCache_ExpiredEntry(t *testing.T) {
+	ctx := context.Background()
+	ms := NewMemoryCache[string](time.Minute)
+	defer ms.Stop()
+
+	// expire the entry without the timing wheel removing it
+	expire := func(key string) {
+		ms.Set(ctx, key, "old")
+
+		ms.lock.Lock()
+		ms.data[ms.config.key(key)].expireAt = time.Now().Add(-time.Second)
+		ms.lock.Unlock()
+	}
+
+	expire("k1")
+	if _, err := ms.Get(ctx, "k1"); err != ErrRecordNotFound {
+		t.Errorf("MemoryCache.Get() error = %v, want = %v", err, ErrRecordNotFound)
+	}
+	if _, _, err := ms.GetVersioned(ctx, "k1"); err != ErrRecordNotFound {
+		t.Errorf("MemoryCache.GetVersioned() error = %v, want = %v", err, ErrRecordNotFound)
+	}
+
+	if set, err := ms.SetNX(ctx, "k1", "new"); err != nil || !set {
+		t.Errorf("MemoryCache.SetNX() got = %v, error = %v, want = %v", set, err, true)
+	}
+
+	expire("k2")
+	if got, found, err := ms.GetOrSet(ctx, "k2", "new"); err != nil || found || got != "new" {
+		t.Errorf("MemoryCache.GetOrSet() got = %v, found = %v, error = %v, want = %v", got, found, err, "new")
+	}
+
+	expire("k3")
+	if swapped, err := ms.CompareAndSwap(ctx, "k3", "old", "new"); err != nil || swapped {
+		t.Errorf("MemoryCache.CompareAndSwap() got = %v, error = %v, want = %v", swapped, err, false)
+	}
+
+	// an expired key is missing, so version 0 matches it
+	expire("k4")
+	if set, err := ms.SetIfVersion(ctx, "k4", "new", 0); err != nil || !set {
+		t.Errorf("MemoryCache.SetIfVersion() got = %v, error = %v, want = %v", set, err, true)
+	}
+
+	for _, key := range []string{"k1", "k2", "k4"} {
+		if got, err := ms.Get(ctx, key); err != nil || got != "new" {
+			t.Errorf("MemoryCache.Get() got = %v, error = %v, want = %v", got, err, "new")
+		}
+	}
+}
+
 func TestNewMemoryCache_InvalidExpiration(t *testing.T) {
 	defer func() {
 		if r := recover(); r == nil {
@@ -137,3 +293,100 @@ func BenchmarkMemoryCache_GetObject(b *testing.B) {
 		ms.Get(ctx, key)
 	}
 }
+
+func TestMemoryCache_KeyOptions(t *testing.T) {
+	ctx := context.Background()
+	long := strings.Repeat("k", 100)
+
+	ms := NewMemoryCache[string](time.Minute,
+		WithKeyPrefix("p:"),
+		WithMaxKeyLength(40),
+		WithKeySanitizer(func(key string) string {
+			return strings.ReplaceAll(key, " ", "_")
+		}),
+	)
+
+	tests := []struct {
+		name      string
+		key       string
+		wantKey   string
+		wantValue string
+	}{
+		{"short", "k1", "p:k1", "v1"},
+		{"sanitized", "user name", "p:user_name", "v2"},
+		{"hashed", long, "p:" + checksumOf(long), "v3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ms.Set(ctx, tt.key, tt.wantValue); err != nil {
+				t.Fatalf("MemoryCache.Set() error = %v", err)
+			}
+
+			if got, err := ms.Get(ctx, tt.key); err != nil || got != tt.wantValue {
+				t.Errorf("MemoryCache.Get() got = %v, error = %v, want = %v", got, err, tt.wantValue)
+			}
+
+			ms.lock.Lock()
+			_, found := ms.data[tt.wantKey]
+			ms.lock.Unlock()
+			if !found {
+				t.Errorf("MemoryCache stored key got = none, want = %v", tt.wantKey)
+			}
+
+			if err := ms.Delete(ctx, tt.key); err != nil {
+				t.Errorf("MemoryCache.Delete() error = %v", err)
+			}
+			if _, err := ms.Get(ctx, tt.key); err != ErrRecordNotFound {
+				t.Errorf("MemoryCache.Get() after delete error = %v, want = %v", err, ErrRecordNotFound)
+			}
+		})
+	}
+}
+
+func TestCacheConfig_MaxKeyLength(t *testing.T) {
+	long := strings.Repeat("k", 100)
+	prefix := strings.Repeat("p", 20)
+
+	tests := []struct {
+		name    string
+		options []CacheOption
+		key     string
+		want    string
+	}{
+		{"fits", []CacheOption{WithKeyPrefix(prefix), WithMaxKeyLength(40)}, "k1", prefix + "k1"},
+		{"hashed", []CacheOption{WithKeyPrefix(prefix), WithMaxKeyLength(60)}, long, prefix + checksumOf(long)},
+		{"truncated prefix", []CacheOption{WithKeyPrefix(prefix), WithMaxKeyLength(40)}, long, prefix[:8] + checksumOf(prefix+long)},
+		{"no room for the prefix", []CacheOption{WithKeyPrefix(prefix), WithMaxKeyLength(32)}, long, checksumOf(prefix + long)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &CacheConfig{}
+			for _, option := range tt.options {
+				option(config)
+			}
+
+			got := config.key(tt.key)
+			if got != tt.want {
+				t.Errorf("CacheConfig.key() got = %v, want = %v", got, tt.want)
+			}
+			if len(got) > config.MaxKeyLength {
+				t.Errorf("CacheConfig.key() length got = %v, want <= %v", len(got), config.MaxKeyLength)
+			}
+		})
+	}
+}
+
+func TestWithMaxKeyLength_TooShort(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("WithMaxKeyLength() did not panic")
+		}
+	}()
+
+	WithMaxKeyLength(31)
+}
+
+// checksumOf returns the md5 hex of the key
+func checksumOf(key string) string {
+	return fmt.Sprintf("%x", md5.Sum([]byte(key)))
+}
@@ -1,8 +1,25 @@
 package gocache
 
+import (
+	"crypto/md5"
+	"fmt"
+)
+
 type CacheConfig struct {
 	// key prefix
 	Prefix string
+	// value codec, JSONCodec is used if not set
+	Codec Codec
+	// maximum length of the prefixed keys, longer keys are replaced by the
+	// prefix and the md5 of the key, 0 means no limit. A prefix too long to
+	// leave room for the md5 is truncated
+	MaxKeyLength int
+	// function rewriting the keys before they are prefixed, nil means keys
+	// are used as they are
+	KeySanitizer func(key string) string
+	// keep a version of every key for VersionedCache, RedisCache stores it in
+	// an additional key
+	Versioning bool
 }
 
 type CacheOption func(*CacheConfig)
@@ -12,3 +29,71 @@ func WithKeyPrefix(prefix string) CacheOption {
 		sc.Prefix = prefix
 	}
 }
+
+// WithCodec sets the codec used to encode values stored outside of the process
+func WithCodec(codec Codec) CacheOption {
+	return func(sc *CacheConfig) {
+		sc.Codec = codec
+	}
+}
+
+// WithMaxKeyLength replaces the keys longer than n bytes, prefix included,
+// with the prefix followed by the md5 of the key. If the prefix doesn't leave
+// room for the 32 hex digits of the md5, it is truncated and hashed along
+// with the key. It panics if n is less than 32
+func WithMaxKeyLength(n int) CacheOption {
+	if n < md5.Size*2 {
+		panic(fmt.Sprintf("gocache: WithMaxKeyLength must be at least %d", md5.Size*2))
+	}
+
+	return func(sc *CacheConfig) {
+		sc.MaxKeyLength = n
+	}
+}
+
+// WithKeySanitizer rewrites the keys before they are prefixed, for example to
+// strip or escape the characters a backend doesn't accept. Keys mapped to the
+// same sanitized key share an entry.
+func WithKeySanitizer(sanitizer func(key string) string) CacheOption {
+	return func(sc *CacheConfig) {
+		sc.KeySanitizer = sanitizer
+	}
+}
+
+// WithVersioning makes RedisCache keep a version of every key, which is
+// required by GetVersioned and SetIfVersion. The version is stored in an
+// additional key and bumped on every write, so it is off by default
+func WithVersioning() CacheOption {
+	return func(sc *CacheConfig) {
+		sc.Versioning = true
+	}
+}
+
+// key returns the key stored in the backend: sanitized, prefixed and
+// hashed if it is too long
+func (c *CacheConfig) key(key string) string {
+	if c.KeySanitizer != nil {
+		key = c.KeySanitizer(key)
+	}
+
+	if c.MaxKeyLength > 0 && len(c.Prefix)+len(key) > c.MaxKeyLength {
+		if room := c.MaxKeyLength - md5.Size*2; len(c.Prefix) > room {
+			// the truncated prefixes of different caches may be equal
+			return c.Prefix[:room] + fmt.Sprintf("%x", md5.Sum([]byte(c.Prefix+key)))
+		}
+
+		return c.Prefix + fmt.Sprintf("%x", md5.Sum([]byte(key)))
+	}
+
+	return c.Prefix + key
+}
+
+// codec returns the configured codec or the default JSONCodec, with errors
+// matching ErrCodec
+func (c *CacheConfig) codec() Codec {
+	if c.Codec == nil {
+		return codecErrors{codec: JSONCodec{}}
+	}
+
+	return codecErrors{codec: c.Codec}
+}
@@ -2,13 +2,90 @@ package gocache
 
 import (
 	"context"
-	"encoding/json"
 	"math/rand"
+	"strconv"
 	"time"
 
 	"github.com/redis/go-redis/v9"
 )
 
+// versionKeySuffix is appended to a key to build the key holding its version
+const versionKeySuffix = ":__version"
+
+// With WithVersioning every write bumps the version stored in KEYS[2], the
+// scripts are called without KEYS[2] otherwise. The version key lives twice
+// as long as the value, so that a value which expires and is written again
+// gets a new version instead of starting over from 1.
+var (
+	// setScript sets KEYS[1] to ARGV[1] with a ttl of ARGV[2] milliseconds
+	// and bumps the version in KEYS[2]
+	setScript = redis.NewScript(`
+redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[2])
+redis.call('INCR', KEYS[2])
+redis.call('PEXPIRE', KEYS[2], ARGV[2] * 2)
+return 1
+`)
+
+	// setNXScript sets KEYS[1] to ARGV[1] with a ttl of ARGV[2] milliseconds
+	// only if it does not exist
+	setNXScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 1 then
+	return 0
+end
+redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[2])
+if KEYS[2] then
+	redis.call('INCR', KEYS[2])
+	redis.call('PEXPIRE', KEYS[2], ARGV[2] * 2)
+end
+return 1
+`)
+
+	// getOrSetScript returns the current value of KEYS[1], or sets it to
+	// ARGV[1] with a ttl of ARGV[2] milliseconds and returns nil
+	getOrSetScript = redis.NewScript(`
+local value = redis.call('GET', KEYS[1])
+if value then
+	return value
+end
+redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[2])
+if KEYS[2] then
+	redis.call('INCR', KEYS[2])
+	redis.call('PEXPIRE', KEYS[2], ARGV[2] * 2)
+end
+return false
+`)
+
+	// compareAndSwapScript sets KEYS[1] to ARGV[2] with a ttl of ARGV[3]
+	// milliseconds only if its current encoded value equals ARGV[1]
+	compareAndSwapScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) ~= ARGV[1] then
+	return 0
+end
+redis.call('SET', KEYS[1], ARGV[2], 'PX', ARGV[3])
+if KEYS[2] then
+	redis.call('INCR', KEYS[2])
+	redis.call('PEXPIRE', KEYS[2], ARGV[3] * 2)
+end
+return 1
+`)
+
+	// setIfVersionScript sets KEYS[1] to ARGV[1] with a ttl of ARGV[2]
+	// milliseconds only if its current version equals ARGV[3]
+	setIfVersionScript = redis.NewScript(`
+local version = '0'
+if redis.call('EXISTS', KEYS[1]) == 1 then
+	version = redis.call('GET', KEYS[2]) or '0'
+end
+if version ~= ARGV[3] then
+	return 0
+end
+redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[2])
+redis.call('INCR', KEYS[2])
+redis.call('PEXPIRE', KEYS[2], ARGV[2] * 2)
+return 1
+`)
+)
+
 type RedisCache[T any] struct {
 	config          *CacheConfig
 	client          *redis.Client
@@ -36,36 +113,44 @@ func NewRedisCache[T any](client *redis.Client, expiration time.Duration, option
 }
 
 func (s RedisCache[T]) Set(ctx context.Context, key string, value T) error {
-	marshaled, err := json.Marshal(value)
+	return s.SetWithTTL(ctx, key, value, 0)
+}
+
+// SetWithTTL sets the value to expire after ttl, capped by the expiration of
+// the cache
+func (s RedisCache[T]) SetWithTTL(ctx context.Context, key string, value T, ttl time.Duration) error {
+	marshaled, err := s.config.codec().Marshal(value)
 	if err != nil {
 		return err
 	}
 
-	// interval [0.95, 1.05)
-	deviation := 1.0 - s.expiryDeviation + rand.Float64()*s.expiryDeviation*2
-	expiration := time.Duration(float64(s.expiration) * deviation)
+	expiration := s.randomExpiration()
+	if ttl > 0 && ttl < s.expiration {
+		expiration = ttl
+	}
 
-	if s.config.Prefix != "" {
-		key = s.config.Prefix + key
+	// PX rejects 0
+	expiration = max(expiration, time.Millisecond)
+
+	if !s.config.Versioning {
+		return backendError(s.client.Set(ctx, s.config.key(key), marshaled, expiration).Err())
 	}
 
-	return s.client.Set(ctx, key, string(marshaled), expiration).Err()
+	return backendError(setScript.Run(ctx, s.client, s.keys(key), string(marshaled), expiration.Milliseconds()).Err())
 }
 
 func (s RedisCache[T]) Get(ctx context.Context, key string) (value T, err error) {
-	if s.config.Prefix != "" {
-		key = s.config.Prefix + key
-	}
+	key = s.config.key(key)
 
 	marshaled, err := s.client.Get(ctx, key).Result()
 	if err == redis.Nil {
 		return value, ErrRecordNotFound
 	}
 	if err != nil {
-		return value, err
+		return value, backendError(err)
 	}
 
-	err = json.Unmarshal([]byte(marshaled), &value)
+	err = s.config.codec().Unmarshal([]byte(marshaled), &value)
 	if err != nil {
 		return value, err
 	}
@@ -73,10 +158,167 @@ func (s RedisCache[T]) Get(ctx context.Context, key string) (value T, err error)
 	return value, nil
 }
 
+// GetWithTTL returns the value of the key along with its remaining lifetime,
+// both are read in one transaction
+func (s RedisCache[T]) GetWithTTL(ctx context.Context, key string) (value T, ttl time.Duration, err error) {
+	key = s.config.key(key)
+
+	pipe := s.client.TxPipeline()
+	get := pipe.Get(ctx, key)
+	pttl := pipe.PTTL(ctx, key)
+	_, err = pipe.Exec(ctx)
+	if err == redis.Nil {
+		return value, 0, ErrRecordNotFound
+	}
+	if err != nil {
+		return value, 0, backendError(err)
+	}
+
+	err = s.config.codec().Unmarshal([]byte(get.Val()), &value)
+	if err != nil {
+		return value, 0, err
+	}
+
+	// a key without expiration has a negative pttl
+	return value, max(pttl.Val(), 0), nil
+}
+
 func (s RedisCache[T]) Delete(ctx context.Context, key string) error {
-	if s.config.Prefix != "" {
-		key = s.config.Prefix + key
+	key = s.config.key(key)
+
+	return backendError(s.client.Del(ctx, key).Err())
+}
+
+// SetNX sets the value only if the key does not exist
+func (s RedisCache[T]) SetNX(ctx context.Context, key string, value T) (bool, error) {
+	marshaled, err := s.config.codec().Marshal(value)
+	if err != nil {
+		return false, err
+	}
+
+	set, err := setNXScript.Run(ctx, s.client, s.keys(key), string(marshaled), s.randomExpiration().Milliseconds()).Int()
+	if err != nil {
+		return false, backendError(err)
+	}
+
+	return set == 1, nil
+}
+
+// GetOrSet returns the existing value of the key, or sets the given value if
+// the key does not exist. The check and the write run in one lua script
+func (s RedisCache[T]) GetOrSet(ctx context.Context, key string, value T) (actual T, loaded bool, err error) {
+	marshaled, err := s.config.codec().Marshal(value)
+	if err != nil {
+		return actual, false, err
 	}
 
-	return s.client.Del(ctx, key).Err()
+	existing, err := getOrSetScript.Run(ctx, s.client, s.keys(key), string(marshaled), s.randomExpiration().Milliseconds()).Text()
+	if err == redis.Nil {
+		return value, false, nil
+	}
+	if err != nil {
+		return actual, false, backendError(err)
+	}
+
+	err = s.config.codec().Unmarshal([]byte(existing), &actual)
+	if err != nil {
+		return actual, false, err
+	}
+
+	return actual, true, nil
+}
+
+// CompareAndSwap sets the key to new only if its current value equals old.
+// Both values are compared in their encoded form, so the codec must encode
+// equal values to identical bytes
+func (s RedisCache[T]) CompareAndSwap(ctx context.Context, key string, old, new T) (bool, error) {
+	codec := s.config.codec()
+	oldMarshaled, err := codec.Marshal(old)
+	if err != nil {
+		return false, err
+	}
+
+	newMarshaled, err := codec.Marshal(new)
+	if err != nil {
+		return false, err
+	}
+
+	swapped, err := compareAndSwapScript.Run(ctx, s.client, s.keys(key), string(oldMarshaled), string(newMarshaled), s.randomExpiration().Milliseconds()).Int()
+	if err != nil {
+		return false, backendError(err)
+	}
+
+	return swapped == 1, nil
+}
+
+// GetVersioned returns the value of the key along with its version, both are
+// read with a single MGET. It returns ErrNotSupported unless the cache was
+// created with WithVersioning
+func (s RedisCache[T]) GetVersioned(ctx context.Context, key string) (value T, version uint64, err error) {
+	if !s.config.Versioning {
+		return value, 0, ErrNotSupported
+	}
+
+	results, err := s.client.MGet(ctx, s.keys(key)...).Result()
+	if err != nil {
+		return value, 0, backendError(err)
+	}
+
+	marshaled, ok := results[0].(string)
+	if !ok {
+		return value, 0, ErrRecordNotFound
+	}
+
+	if v, ok := results[1].(string); ok {
+		version, err = strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			return value, 0, err
+		}
+	}
+
+	err = s.config.codec().Unmarshal([]byte(marshaled), &value)
+	if err != nil {
+		return value, 0, err
+	}
+
+	return value, version, nil
+}
+
+// SetIfVersion sets the value only if the current version of the key equals
+// version, version 0 matches a missing key. It returns ErrNotSupported unless
+// the cache was created with WithVersioning
+func (s RedisCache[T]) SetIfVersion(ctx context.Context, key string, value T, version uint64) (bool, error) {
+	if !s.config.Versioning {
+		return false, ErrNotSupported
+	}
+
+	marshaled, err := s.config.codec().Marshal(value)
+	if err != nil {
+		return false, err
+	}
+
+	set, err := setIfVersionScript.Run(ctx, s.client, s.keys(key), string(marshaled), s.randomExpiration().Milliseconds(), strconv.FormatUint(version, 10)).Int()
+	if err != nil {
+		return false, backendError(err)
+	}
+
+	return set == 1, nil
+}
+
+// keys returns the keys passed to the scripts: the stored key, followed by the
+// key of its version with WithVersioning. The version key goes through the
+// same sanitizing and length limit as the stored key
+func (s RedisCache[T]) keys(key string) []string {
+	if !s.config.Versioning {
+		return []string{s.config.key(key)}
+	}
+
+	return []string{s.config.key(key), s.config.key(key + versionKeySuffix)}
+}
+
+// randomExpiration returns the expiration randomized by expiryDeviation
+func (s RedisCache[T]) randomExpiration() time.Duration {
+	// interval [0.95, 1.05)
+	deviation := 1.0 - s.expiryDeviation + rand.Float64()*s.expiryDeviation*2
+	return time.Duration(float64(s.expiration) * deviation)
 }
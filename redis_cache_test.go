@@ -2,6 +2,7 @@ package gocache
 
 import (
 	"context"
+	"strings"
 	"testing"
 	"time"
 
@@ -73,6 +74,173 @@ func TestRedisCache_SetTTLAndGet(t *testing.T) {
 	}
 }
 
+func TestRedisCache_AtomicOperations(t *testing.T) {
+	ctx := context.Background()
+	client := requireRedis(t)
+
+	rs := NewRedisCache[*getResponse](client, time.Minute, WithKeyPrefix("atomic:"))
+	key := "k1"
+	rs.Delete(ctx, key)
+
+	set, err := rs.SetNX(ctx, key, &getResponse{Value: 1})
+	if err != nil || !set {
+		t.Errorf("RedisCache.SetNX() got = %v, error = %v, want = %v", set, err, true)
+	}
+
+	set, err = rs.SetNX(ctx, key, &getResponse{Value: 2})
+	if err != nil || set {
+		t.Errorf("RedisCache.SetNX() got = %v, error = %v, want = %v", set, err, false)
+	}
+
+	actual, loaded, err := rs.GetOrSet(ctx, key, &getResponse{Value: 3})
+	if err != nil || !loaded || actual.Value != 1 {
+		t.Errorf("RedisCache.GetOrSet() got = %v, loaded = %v, error = %v", actual, loaded, err)
+	}
+
+	swapped, err := rs.CompareAndSwap(ctx, key, &getResponse{Value: 1}, &getResponse{Value: 4})
+	if err != nil || !swapped {
+		t.Errorf("RedisCache.CompareAndSwap() got = %v, error = %v, want = %v", swapped, err, true)
+	}
+
+	swapped, err = rs.CompareAndSwap(ctx, key, &getResponse{Value: 1}, &getResponse{Value: 5})
+	if err != nil || swapped {
+		t.Errorf("RedisCache.CompareAndSwap() got = %v, error = %v, want = %v", swapped, err, false)
+	}
+
+	got, err := rs.Get(ctx, key)
+	if err != nil || got.Value != 4 {
+		t.Errorf("RedisCache.Get() got = %v, error = %v, want = %v", got, err, 4)
+	}
+
+	rs.Delete(ctx, key)
+
+	actual, loaded, err = rs.GetOrSet(ctx, key, &getResponse{Value: 6})
+	if err != nil || loaded || actual.Value != 6 {
+		t.Errorf("RedisCache.GetOrSet() got = %v, loaded = %v, error = %v", actual, loaded, err)
+	}
+}
+
+func TestRedisCache_SetIfVersion(t *testing.T) {
+	ctx := context.Background()
+	client := requireRedis(t)
+
+	rs := NewRedisCache[string](client, time.Minute, WithKeyPrefix("versioned:"), WithVersioning())
+	key := "k1"
+	rs.Delete(ctx, key)
+	client.Del(ctx, "versioned:"+key+versionKeySuffix)
+
+	set, err := rs.SetIfVersion(ctx, key, "v1", 0)
+	if err != nil || !set {
+		t.Errorf("RedisCache.SetIfVersion() got = %v, error = %v, want = %v", set, err, true)
+	}
+
+	got, version, err := rs.GetVersioned(ctx, key)
+	if err != nil || got != "v1" || version != 1 {
+		t.Errorf("RedisCache.GetVersioned() got = %v, version = %v, error = %v", got, version, err)
+	}
+
+	// a concurrent writer bumps the version
+	if err := rs.Set(ctx, key, "v2"); err != nil {
+		t.Errorf("RedisCache.Set() error = %v", err)
+	}
+
+	set, err = rs.SetIfVersion(ctx, key, "v3", version)
+	if err != nil || set {
+		t.Errorf("RedisCache.SetIfVersion() got = %v, error = %v, want = %v", set, err, false)
+	}
+
+	set, err = rs.SetIfVersion(ctx, key, "v3", version+1)
+	if err != nil || !set {
+		t.Errorf("RedisCache.SetIfVersion() got = %v, error = %v, want = %v", set, err, true)
+	}
+
+	// the version survives a delete, so an old version never matches again
+	rs.Delete(ctx, key)
+	if err := rs.Set(ctx, key, "v4"); err != nil {
+		t.Errorf("RedisCache.Set() error = %v", err)
+	}
+
+	_, version, err = rs.GetVersioned(ctx, key)
+	if err != nil || version != 4 {
+		t.Errorf("RedisCache.GetVersioned() version = %v, error = %v, want = %v", version, err, 4)
+	}
+}
+
+func TestRedisCache_TTL(t *testing.T) {
+	ctx := context.Background()
+	client := requireRedis(t)
+
+	rs := NewRedisCache[string](client, time.Minute, WithKeyPrefix("ttl:"))
+	key := "k1"
+	rs.Delete(ctx, key)
+
+	if _, _, err := rs.GetWithTTL(ctx, key); err != ErrRecordNotFound {
+		t.Errorf("RedisCache.GetWithTTL() error got = %v, want = %v", err, ErrRecordNotFound)
+	}
+
+	if err := rs.SetWithTTL(ctx, key, "v1", 10*time.Second); err != nil {
+		t.Errorf("RedisCache.SetWithTTL() error = %v", err)
+	}
+
+	got, ttl, err := rs.GetWithTTL(ctx, key)
+	if err != nil || got != "v1" || ttl <= 0 || ttl > 10*time.Second {
+		t.Errorf("RedisCache.GetWithTTL() got = %v, ttl = %v, error = %v", got, ttl, err)
+	}
+
+	// capped by the expiration of the cache
+	if err := rs.SetWithTTL(ctx, key, "v2", time.Hour); err != nil {
+		t.Errorf("RedisCache.SetWithTTL() error = %v", err)
+	}
+
+	_, ttl, err = rs.GetWithTTL(ctx, key)
+	if err != nil || ttl > time.Minute*105/100 {
+		t.Errorf("RedisCache.GetWithTTL() ttl = %v, error = %v", ttl, err)
+	}
+}
+
+func TestRedisCache_Versioning(t *testing.T) {
+	ctx := context.Background()
+	client := requireRedis(t)
+
+	// without versioning writes are plain SETs
+	rs := NewRedisCache[string](client, time.Minute, WithKeyPrefix("unversioned:"))
+	client.Del(ctx, "unversioned:k1"+versionKeySuffix)
+	if err := rs.Set(ctx, "k1", "v1"); err != nil {
+		t.Errorf("RedisCache.Set() error = %v", err)
+	}
+	if _, err := rs.SetNX(ctx, "k2", "v2"); err != nil {
+		t.Errorf("RedisCache.SetNX() error = %v", err)
+	}
+	if n, _ := client.Exists(ctx, "unversioned:k1"+versionKeySuffix).Result(); n != 0 {
+		t.Errorf("version key exists = %v, want = %v", n, 0)
+	}
+	if _, _, err := rs.GetVersioned(ctx, "k1"); err != ErrNotSupported {
+		t.Errorf("RedisCache.GetVersioned() error = %v, want = %v", err, ErrNotSupported)
+	}
+	if _, err := rs.SetIfVersion(ctx, "k1", "v2", 0); err != ErrNotSupported {
+		t.Errorf("RedisCache.SetIfVersion() error = %v, want = %v", err, ErrNotSupported)
+	}
+
+	// the version key respects the maximum key length as well
+	maxLength := len("versioned:") + 32
+	versioned := NewRedisCache[string](client, time.Minute, WithKeyPrefix("versioned:"), WithVersioning(), WithMaxKeyLength(maxLength))
+	long := strings.Repeat("k", 100)
+	if err := versioned.Set(ctx, long, "v1"); err != nil {
+		t.Errorf("RedisCache.Set() error = %v", err)
+	}
+	for _, key := range versioned.keys(long) {
+		if len(key) > maxLength {
+			t.Errorf("RedisCache key length got = %v, want <= %v", len(key), maxLength)
+		}
+		if n, _ := client.Exists(ctx, key).Result(); n != 1 {
+			t.Errorf("key %v exists = %v, want = %v", key, n, 1)
+		}
+	}
+	if _, version, err := versioned.GetVersioned(ctx, long); err != nil || version == 0 {
+		t.Errorf("RedisCache.GetVersioned() version = %v, error = %v", version, err)
+	}
+}
+
 func TestNewRedisCache_InvalidExpiration(t *testing.T) {
 	defer func() {
 		if r := recover(); r == nil {
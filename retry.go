@@ -0,0 +1,81 @@
+package gocache
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"time"
+)
+
+// RetryPolicy controls how a failed load is retried
+type RetryPolicy struct {
+	// maximum number of attempts, the first one included
+	MaxAttempts int
+	// backoff before the second attempt
+	InitialBackoff time.Duration
+	// upper bound of the backoff, 0 means no bound
+	MaxBackoff time.Duration
+	// factor applied to the backoff after each attempt, 2 if not set
+	Multiplier float64
+	// randomization range of each backoff in [0, 1], 0.2 means ±20%
+	Jitter float64
+	// reports whether an error is worth retrying, if not set every error is
+	// retried except context cancellation and deadline errors
+	Retryable func(err error) bool
+}
+
+// retry calls fn until it succeeds, returns a non retryable error or the
+// attempts are exhausted. Backoffs are interrupted by the context, in which
+// case the last error is returned
+func (p *RetryPolicy) retry(ctx context.Context, fn func(ctx context.Context) error) error {
+	backoff := p.InitialBackoff
+	for attempt := 1; ; attempt++ {
+		err := fn(ctx)
+		if err == nil || attempt >= p.MaxAttempts || !p.retryable(err) {
+			return err
+		}
+
+		timer := time.NewTimer(p.jitter(backoff))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+
+		backoff = p.next(backoff)
+	}
+}
+
+func (p *RetryPolicy) retryable(err error) bool {
+	if p.Retryable != nil {
+		return p.Retryable(err)
+	}
+
+	return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+}
+
+// next returns the backoff following the given one
+func (p *RetryPolicy) next(backoff time.Duration) time.Duration {
+	multiplier := p.Multiplier
+	if multiplier <= 0 {
+		multiplier = 2
+	}
+
+	backoff = time.Duration(float64(backoff) * multiplier)
+	if p.MaxBackoff > 0 && backoff > p.MaxBackoff {
+		backoff = p.MaxBackoff
+	}
+
+	return backoff
+}
+
+// jitter randomizes the backoff in [1 - Jitter, 1 + Jitter)
+func (p *RetryPolicy) jitter(backoff time.Duration) time.Duration {
+	if p.Jitter <= 0 {
+		return backoff
+	}
+
+	deviation := 1.0 - p.Jitter + rand.Float64()*p.Jitter*2
+	return time.Duration(float64(backoff) * deviation)
+}
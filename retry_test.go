@@ -0,0 +1,106 @@
+package gocache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	p := &RetryPolicy{InitialBackoff: 10 * time.Millisecond, MaxBackoff: 50 * time.Millisecond}
+
+	backoff := p.InitialBackoff
+	for _, want := range []time.Duration{20, 40, 50, 50} {
+		backoff = p.next(backoff)
+		if backoff != want*time.Millisecond {
+			t.Errorf("RetryPolicy.next() = %v, want = %v", backoff, want*time.Millisecond)
+		}
+	}
+
+	p.Jitter = 0.2
+	for index := 0; index < 100; index++ {
+		if got := p.jitter(100 * time.Millisecond); got < 80*time.Millisecond || got >= 120*time.Millisecond {
+			t.Errorf("RetryPolicy.jitter() = %v, want in [80ms, 120ms)", got)
+		}
+	}
+}
+
+func TestLoadableCache_Retry(t *testing.T) {
+	ctx := context.Background()
+	errTransient := errors.New("transient")
+	errPermanent := errors.New("permanent")
+
+	lc := NewLoadableCache[string, string](NewMemoryCache[string](time.Minute), WithRetry(RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: 10 * time.Millisecond,
+		Retryable: func(err error) bool {
+			return err == errTransient
+		},
+	}))
+
+	var calls int64
+	fn := func(ctx context.Context, arg string) (string, error) {
+		time.Sleep(50 * time.Millisecond)
+		switch atomic.AddInt64(&calls, 1) {
+		case 1, 2:
+			return "", errTransient
+		default:
+			return "v1", nil
+		}
+	}
+
+	// the followers share the retries of the leader
+	wg := &sync.WaitGroup{}
+	for index := 0; index < 10; index++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			got, err := lc.LoadCtx(ctx, fn, "k1")
+			if err != nil || got != "v1" {
+				t.Errorf("LoadableCache.LoadCtx() got = %v, error = %v, want = %v", got, err, "v1")
+			}
+		}()
+	}
+	wg.Wait()
+
+	if calls != 3 {
+		t.Errorf("load function calls = %v, want = %v", calls, 3)
+	}
+
+	// non retryable errors fail immediately
+	calls = 0
+	_, err := lc.LoadCtx(ctx, func(ctx context.Context, arg string) (string, error) {
+		calls++
+		return "", errPermanent
+	}, "k2")
+	if err != errPermanent || calls != 1 {
+		t.Errorf("LoadableCache.LoadCtx() error = %v, calls = %v", err, calls)
+	}
+}
+
+func TestLoadableCache_RetryContext(t *testing.T) {
+	errTransient := errors.New("transient")
+	lc := NewLoadableCache[string, string](NewMemoryCache[string](time.Minute), WithRetry(RetryPolicy{
+		MaxAttempts:    10,
+		InitialBackoff: time.Minute,
+	}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	// the backoff is interrupted by the context
+	start := time.Now()
+	_, err := lc.LoadCtx(ctx, func(ctx context.Context, arg string) (string, error) {
+		return "", errTransient
+	}, "k1")
+	if err != errTransient {
+		t.Errorf("LoadableCache.LoadCtx() error = %v, want = %v", err, errTransient)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("LoadableCache.LoadCtx() returned after %v, want about 50ms", elapsed)
+	}
+}
@@ -2,8 +2,8 @@ package gocache
 
 import (
 	"context"
-	"fmt"
 	"sync"
+	"time"
 )
 
 type SingleFlight[T, K any] interface {
@@ -11,24 +11,52 @@ type SingleFlight[T, K any] interface {
 	DoEx(fn LoadFunction[T, K], arg T) (val K, fresh bool, err error)
 	DoCtx(ctx context.Context, fn LoadFunctionCtx[T, K], arg T) (K, error)
 	DoExCtx(ctx context.Context, fn LoadFunctionCtx[T, K], arg T) (val K, fresh bool, err error)
+	// Forget drops the in-flight or shared call of arg, so that the next
+	// caller starts a new call instead of waiting for the current one
+	Forget(arg T)
+	// DoChan is like DoCtx but returns a channel that receives the result
+	// when it is ready, so that callers can select on it
+	DoChan(ctx context.Context, fn LoadFunctionCtx[T, K], arg T) <-chan Result[K]
+}
+
+// Result holds the results of DoChan
+type Result[K any] struct {
+	Val K
+	Err error
+	// Shared reports whether the result was given to multiple callers
+	Shared bool
 }
 
 type singleFlightGroup[T, K any] struct {
-	calls map[string]*call[K]
-	lock  sync.Mutex
+	config  *LoadConfig
+	keyFunc KeyFunction[T]
+	calls   map[string]*call[K]
+	lock    sync.Mutex
 }
 
 type call[T any] struct {
-	wg  sync.WaitGroup
-	val T
-	err error
+	// closed when val and err are set
+	done chan struct{}
+	val  T
+	err  error
+	// number of callers that joined the call, guarded by the group lock
+	dups int
 }
 
 // NewSingleFlight returns a generic single flight.
-func NewSingleFlight[T, K any]() SingleFlight[T, K] {
-	return &singleFlightGroup[T, K]{
-		calls: make(map[string]*call[K]),
+func NewSingleFlight[T, K any](options ...LoadOption) SingleFlight[T, K] {
+	g := &singleFlightGroup[T, K]{
+		config: &LoadConfig{},
+		calls:  make(map[string]*call[K]),
+	}
+
+	for _, option := range options {
+		option(g.config)
 	}
+
+	g.keyFunc = keyFunction[T](g.config)
+
+	return g
 }
 
 func (g *singleFlightGroup[T, K]) Do(fn LoadFunction[T, K], arg T) (K, error) {
@@ -42,9 +70,10 @@ func (g *singleFlightGroup[T, K]) DoCtx(ctx context.Context, fn LoadFunctionCtx[
 }
 
 func (g *singleFlightGroup[T, K]) DoEx(fn LoadFunction[T, K], arg T) (val K, fresh bool, err error) {
-	key := GenerateCacheKey(arg)
-	c, done := g.createCall(key)
-	if done {
+	key := g.keyFunc(arg)
+	c, leader := g.createCall(key)
+	if !leader {
+		<-c.done
 		return c.val, false, c.err
 	}
 
@@ -52,31 +81,83 @@ func (g *singleFlightGroup[T, K]) DoEx(fn LoadFunction[T, K], arg T) (val K, fre
 	return c.val, true, c.err
 }
 
+// DoExCtx calls fn once for concurrent callers with the same arg. A caller
+// waiting for another one's call returns ctx.Err() as soon as its context is
+// done, the call itself keeps running.
 func (g *singleFlightGroup[T, K]) DoExCtx(ctx context.Context, fn LoadFunctionCtx[T, K], arg T) (val K, fresh bool, err error) {
-	key := GenerateCacheKey(arg)
-	c, done := g.createCall(key)
-	if done {
-		return c.val, false, c.err
+	key := g.keyFunc(arg)
+	c, leader := g.createCall(key)
+	if leader {
+		if !g.config.DetachLoad {
+			g.makeCallCtx(ctx, c, key, fn, arg)
+			return c.val, true, c.err
+		}
+
+		go g.makeCallCtx(context.WithoutCancel(ctx), c, key, fn, arg)
 	}
 
-	g.makeCallCtx(ctx, c, key, fn, arg)
-	return c.val, true, c.err
+	select {
+	case <-c.done:
+		return c.val, leader, c.err
+	case <-ctx.Done():
+		return val, leader, ctx.Err()
+	}
 }
 
-func (g *singleFlightGroup[T, K]) createCall(key string) (c *call[K], done bool) {
+// DoChan calls fn once for concurrent callers with the same arg like DoExCtx
+// does, the result is sent to the returned channel which is never closed
+func (g *singleFlightGroup[T, K]) DoChan(ctx context.Context, fn LoadFunctionCtx[T, K], arg T) <-chan Result[K] {
+	ch := make(chan Result[K], 1)
+
+	key := g.keyFunc(arg)
+	c, leader := g.createCall(key)
+	if leader {
+		if !g.config.DetachLoad {
+			go func() {
+				g.makeCallCtx(ctx, c, key, fn, arg)
+				ch <- Result[K]{Val: c.val, Err: c.err, Shared: g.shared(c)}
+			}()
+			return ch
+		}
+
+		go g.makeCallCtx(context.WithoutCancel(ctx), c, key, fn, arg)
+	}
+
+	go func() {
+		select {
+		case <-c.done:
+			ch <- Result[K]{Val: c.val, Err: c.err, Shared: !leader || g.shared(c)}
+		case <-ctx.Done():
+			ch <- Result[K]{Err: ctx.Err()}
+		}
+	}()
+
+	return ch
+}
+
+// shared reports whether other callers joined the call
+func (g *singleFlightGroup[T, K]) shared(c *call[K]) bool {
 	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	return c.dups > 0
+}
+
+// createCall returns the in-flight call of the key, or registers a new one if
+// there is none, in which case the caller is the leader and must make it
+func (g *singleFlightGroup[T, K]) createCall(key string) (c *call[K], leader bool) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
 	if c, ok := g.calls[key]; ok {
-		g.lock.Unlock()
-		c.wg.Wait()
-		return c, true
+		c.dups++
+		return c, false
 	}
 
-	c = new(call[K])
-	c.wg.Add(1)
+	c = &call[K]{done: make(chan struct{})}
 	g.calls[key] = c
-	g.lock.Unlock()
 
-	return c, false
+	return c, true
 }
 
 func (g *singleFlightGroup[T, K]) makeCall(c *call[K], key string, fn LoadFunction[T, K], arg T) {
@@ -84,12 +165,9 @@ func (g *singleFlightGroup[T, K]) makeCall(c *call[K], key string, fn LoadFuncti
 		if r := recover(); r != nil {
 			// convert the panic to an error so that waiters don't get a zero
 			// value with a nil error
-			c.err = fmt.Errorf("single flight function panic: %v", r)
+			c.err = newPanicError(r)
 		}
-		g.lock.Lock()
-		delete(g.calls, key)
-		g.lock.Unlock()
-		c.wg.Done()
+		g.finishCall(c, key)
 	}()
 
 	c.val, c.err = fn(arg)
@@ -100,13 +178,42 @@ func (g *singleFlightGroup[T, K]) makeCallCtx(ctx context.Context, c *call[K], k
 		if r := recover(); r != nil {
 			// convert the panic to an error so that waiters don't get a zero
 			// value with a nil error
-			c.err = fmt.Errorf("single flight function panic: %v", r)
+			c.err = newPanicError(r)
 		}
-		g.lock.Lock()
-		delete(g.calls, key)
-		g.lock.Unlock()
-		c.wg.Done()
+		g.finishCall(c, key)
 	}()
 
 	c.val, c.err = fn(ctx, arg)
 }
+
+// finishCall wakes up the waiters of the call and removes it from the group,
+// either immediately or once the share window has passed
+func (g *singleFlightGroup[T, K]) finishCall(c *call[K], key string) {
+	if g.config.ShareWindow > 0 && c.err == nil {
+		time.AfterFunc(g.config.ShareWindow, func() {
+			g.removeCall(c, key)
+		})
+	} else {
+		g.removeCall(c, key)
+	}
+
+	close(c.done)
+}
+
+// removeCall removes the call from the group unless it has been forgotten
+// and replaced by a newer call already
+func (g *singleFlightGroup[T, K]) removeCall(c *call[K], key string) {
+	g.lock.Lock()
+	if g.calls[key] == c {
+		delete(g.calls, key)
+	}
+	g.lock.Unlock()
+}
+
+func (g *singleFlightGroup[T, K]) Forget(arg T) {
+	key := g.keyFunc(arg)
+
+	g.lock.Lock()
+	delete(g.calls, key)
+	g.lock.Unlock()
+}
@@ -2,6 +2,7 @@ package gocache
 
 import (
 	"context"
+	"strconv"
 	"sync"
 	"sync/atomic"
 	"testing"
@@ -269,3 +270,202 @@ func TestSingleFlight_Panic(t *testing.T) {
 		t.Errorf("SingleFlight.Do() panic not propagated to all callers, got %d, want %d", errCount, 100)
 	}
 }
+
+func TestSingleFlight_DoCtx_FollowerCancel(t *testing.T) {
+	sf := NewSingleFlight[string, string]()
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	fn := func(ctx context.Context, arg string) (string, error) {
+		close(started)
+		<-release
+		return "v1", nil
+	}
+
+	leaderDone := make(chan struct{})
+	go func() {
+		defer close(leaderDone)
+
+		got, err := sf.DoCtx(context.Background(), fn, "k1")
+		if err != nil || got != "v1" {
+			t.Errorf("SingleFlight.DoCtx() got = %v, error = %v, want = %v", got, err, "v1")
+		}
+	}()
+
+	<-started
+
+	// the follower gives up as soon as its deadline passes, even though the
+	// leader is still running
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := sf.DoCtx(ctx, fn, "k1")
+	if err != context.DeadlineExceeded {
+		t.Errorf("SingleFlight.DoCtx() error = %v, want = %v", err, context.DeadlineExceeded)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("SingleFlight.DoCtx() returned after %v, want about 50ms", elapsed)
+	}
+
+	close(release)
+	<-leaderDone
+}
+
+func TestSingleFlight_DoCtx_DetachedLoad(t *testing.T) {
+	sf := NewSingleFlight[string, string](WithDetachedLoad())
+
+	started := make(chan struct{})
+	fn := func(ctx context.Context, arg string) (string, error) {
+		close(started)
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-time.After(100 * time.Millisecond):
+			return "v1", nil
+		}
+	}
+
+	// the first caller gives up early
+	ctx, cancel := context.WithCancel(context.Background())
+	leaderDone := make(chan struct{})
+	go func() {
+		defer close(leaderDone)
+
+		if _, err := sf.DoCtx(ctx, fn, "k1"); err != context.Canceled {
+			t.Errorf("SingleFlight.DoCtx() error = %v, want = %v", err, context.Canceled)
+		}
+	}()
+
+	<-started
+	cancel()
+	<-leaderDone
+
+	// the load was not cancelled with the first caller, so the follower
+	// still gets its result
+	got, fresh, err := sf.DoExCtx(context.Background(), fn, "k1")
+	if err != nil || got != "v1" || fresh {
+		t.Errorf("SingleFlight.DoExCtx() got = %v, fresh = %v, error = %v", got, fresh, err)
+	}
+}
+
+func TestSingleFlight_Forget(t *testing.T) {
+	sf := NewSingleFlight[string, string]()
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	go sf.DoCtx(context.Background(), func(ctx context.Context, arg string) (string, error) {
+		close(started)
+		<-release
+		return "v1", nil
+	}, "k1")
+
+	<-started
+	sf.Forget("k1")
+
+	// the next caller starts a new call instead of waiting for the first one
+	got, fresh, err := sf.DoExCtx(context.Background(), func(ctx context.Context, arg string) (string, error) {
+		return "v2", nil
+	}, "k1")
+	if err != nil || got != "v2" || !fresh {
+		t.Errorf("SingleFlight.DoExCtx() got = %v, fresh = %v, error = %v", got, fresh, err)
+	}
+
+	close(release)
+}
+
+func TestSingleFlight_ShareWindow(t *testing.T) {
+	sf := NewSingleFlight[string, int](WithShareWindow(200 * time.Millisecond))
+
+	var calls int
+	fn := func(arg string) (int, error) {
+		calls++
+		return calls, nil
+	}
+
+	got, fresh, err := sf.DoEx(fn, "k1")
+	if err != nil || got != 1 || !fresh {
+		t.Errorf("SingleFlight.DoEx() got = %v, fresh = %v, error = %v", got, fresh, err)
+	}
+
+	// the call has returned but its result is still shared
+	got, fresh, err = sf.DoEx(fn, "k1")
+	if err != nil || got != 1 || fresh {
+		t.Errorf("SingleFlight.DoEx() within window got = %v, fresh = %v, error = %v", got, fresh, err)
+	}
+
+	time.Sleep(300 * time.Millisecond)
+
+	got, fresh, err = sf.DoEx(fn, "k1")
+	if err != nil || got != 2 || !fresh {
+		t.Errorf("SingleFlight.DoEx() after window got = %v, fresh = %v, error = %v", got, fresh, err)
+	}
+}
+
+func TestSingleFlight_DoChan(t *testing.T) {
+	sf := NewSingleFlight[string, string]()
+
+	release := make(chan struct{})
+	fn := func(ctx context.Context, arg string) (string, error) {
+		<-release
+		return "v1", nil
+	}
+
+	ctx := context.Background()
+	ch1 := sf.DoChan(ctx, fn, "k1")
+	ch2 := sf.DoChan(ctx, fn, "k1")
+
+	select {
+	case <-ch1:
+		t.Errorf("SingleFlight.DoChan() returned before the function")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+
+	for _, ch := range []<-chan Result[string]{ch1, ch2} {
+		result := <-ch
+		if result.Err != nil || result.Val != "v1" || !result.Shared {
+			t.Errorf("SingleFlight.DoChan() got = %+v", result)
+		}
+	}
+
+	// a panic is delivered as an error
+	result := <-sf.DoChan(ctx, func(ctx context.Context, arg string) (string, error) {
+		panic("boom")
+	}, "k2")
+	if result.Err == nil || result.Shared {
+		t.Errorf("SingleFlight.DoChan() with panic got = %+v", result)
+	}
+}
+
+func TestSingleFlight_WithKeyFunc(t *testing.T) {
+	// arguments with the same key share a call
+	sf := NewSingleFlight[int, int](WithKeyFunc(func(arg int) string {
+		return strconv.Itoa(arg % 2)
+	}))
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	go sf.Do(func(arg int) (int, error) {
+		close(started)
+		<-release
+		return arg, nil
+	}, 1)
+	<-started
+
+	result := make(chan int)
+	go func() {
+		got, _ := sf.Do(func(arg int) (int, error) {
+			return arg, nil
+		}, 3)
+		result <- got
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+
+	if got := <-result; got != 1 {
+		t.Errorf("SingleFlight.Do() got = %v, want = %v", got, 1)
+	}
+}
@@ -0,0 +1,319 @@
+package gocache
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SQLDialect selects the SQL syntax used by SQLCache
+type SQLDialect int
+
+const (
+	SQLite SQLDialect = iota
+	Postgres
+	MySQL
+)
+
+type SQLCacheOptions struct {
+	// name of the cache table, default "gocache"
+	Table string
+	// SQL syntax of the database, default SQLite
+	Dialect SQLDialect
+	// interval of the purge of expired rows, default 1 minute, a negative
+	// value disables the purge
+	PurgeInterval time.Duration
+}
+
+// SQLCache stores encoded values in a table with the columns cache_key,
+// cache_value and expires_at (unix milliseconds). Expired rows are never
+// returned and are deleted by a periodic purge.
+type SQLCache[T any] struct {
+	config          *CacheConfig
+	options         SQLCacheOptions
+	db              *sql.DB
+	expiration      time.Duration
+	expiryDeviation float64
+
+	stop      chan struct{}
+	stopped   chan struct{}
+	closeOnce sync.Once
+}
+
+func NewSQLCache[T any](db *sql.DB, expiration time.Duration, opts SQLCacheOptions, options ...CacheOption) *SQLCache[T] {
+	if expiration <= 0 {
+		panic("gocache: NewSQLCache expiration must be positive")
+	}
+
+	if opts.Table == "" {
+		opts.Table = "gocache"
+	}
+
+	if opts.PurgeInterval == 0 {
+		opts.PurgeInterval = time.Minute
+	}
+
+	s := &SQLCache[T]{
+		config:          &CacheConfig{},
+		options:         opts,
+		db:              db,
+		expiration:      expiration,
+		expiryDeviation: ExpiryDeviation,
+		stop:            make(chan struct{}),
+		stopped:         make(chan struct{}),
+	}
+
+	for _, option := range options {
+		option(s.config)
+	}
+
+	if opts.PurgeInterval > 0 {
+		go s.run()
+	} else {
+		close(s.stopped)
+	}
+
+	return s
+}
+
+// CreateTable creates the cache table and its index on expires_at if they do
+// not exist
+func (s *SQLCache[T]) CreateTable(ctx context.Context) error {
+	table := s.options.Table
+
+	var queries []string
+	switch s.options.Dialect {
+	case Postgres:
+		queries = []string{
+			fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (cache_key TEXT PRIMARY KEY, cache_value BYTEA NOT NULL, expires_at BIGINT NOT NULL)`, table),
+			fmt.Sprintf(`CREATE INDEX IF NOT EXISTS %s_expires_at ON %s (expires_at)`, table, table),
+		}
+	case MySQL:
+		// MySQL has no CREATE INDEX IF NOT EXISTS, the index is declared with the table
+		queries = []string{
+			fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (cache_key VARCHAR(255) PRIMARY KEY, cache_value LONGBLOB NOT NULL, expires_at BIGINT NOT NULL, INDEX %s_expires_at (expires_at))`, table, table),
+		}
+	default:
+		queries = []string{
+			fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (cache_key TEXT PRIMARY KEY, cache_value BLOB NOT NULL, expires_at INTEGER NOT NULL)`, table),
+			fmt.Sprintf(`CREATE INDEX IF NOT EXISTS %s_expires_at ON %s (expires_at)`, table, table),
+		}
+	}
+
+	for _, query := range queries {
+		_, err := s.db.ExecContext(ctx, query)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *SQLCache[T]) Set(ctx context.Context, key string, value T) error {
+	return s.SetMulti(ctx, map[string]T{key: value})
+}
+
+func (s *SQLCache[T]) Get(ctx context.Context, key string) (value T, err error) {
+	key = s.config.key(key)
+
+	query := fmt.Sprintf(`SELECT cache_value FROM %s WHERE cache_key = %s AND expires_at > %s`,
+		s.options.Table, s.placeholder(1), s.placeholder(2))
+
+	var marshaled []byte
+	err = s.db.QueryRowContext(ctx, query, key, time.Now().UnixMilli()).Scan(&marshaled)
+	if err == sql.ErrNoRows {
+		return value, ErrRecordNotFound
+	}
+	if err != nil {
+		return value, backendError(err)
+	}
+
+	err = s.config.codec().Unmarshal(marshaled, &value)
+	if err != nil {
+		return value, err
+	}
+
+	return value, nil
+}
+
+func (s *SQLCache[T]) Delete(ctx context.Context, key string) error {
+	return s.DeleteMulti(ctx, []string{key})
+}
+
+// GetMulti gets the values of the keys in one query, missing and expired keys
+// are left out of the result
+func (s *SQLCache[T]) GetMulti(ctx context.Context, keys []string) (map[string]T, error) {
+	values := make(map[string]T, len(keys))
+	if len(keys) == 0 {
+		return values, nil
+	}
+
+	originals := make(map[string]string, len(keys))
+	args := make([]any, 0, len(keys)+1)
+	args = append(args, time.Now().UnixMilli())
+	placeholders := make([]string, 0, len(keys))
+	for index, key := range keys {
+		sqlKey := s.config.key(key)
+		originals[sqlKey] = key
+		args = append(args, sqlKey)
+		placeholders = append(placeholders, s.placeholder(index+2))
+	}
+
+	query := fmt.Sprintf(`SELECT cache_key, cache_value FROM %s WHERE expires_at > %s AND cache_key IN (%s)`,
+		s.options.Table, s.placeholder(1), strings.Join(placeholders, ", "))
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, backendError(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var key string
+		var marshaled []byte
+		err = rows.Scan(&key, &marshaled)
+		if err != nil {
+			return nil, backendError(err)
+		}
+
+		var value T
+		err = s.config.codec().Unmarshal(marshaled, &value)
+		if err != nil {
+			return nil, err
+		}
+
+		values[originals[key]] = value
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, backendError(err)
+	}
+
+	return values, nil
+}
+
+// SetMulti upserts the values in one transaction
+func (s *SQLCache[T]) SetMulti(ctx context.Context, values map[string]T) error {
+	if len(values) == 0 {
+		return nil
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return backendError(err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, s.upsertQuery())
+	if err != nil {
+		return backendError(err)
+	}
+	defer stmt.Close()
+
+	for key, value := range values {
+		marshaled, err := s.config.codec().Marshal(value)
+		if err != nil {
+			return err
+		}
+
+		key = s.config.key(key)
+
+		_, err = stmt.ExecContext(ctx, key, marshaled, time.Now().Add(s.randomExpiration()).UnixMilli())
+		if err != nil {
+			return backendError(err)
+		}
+	}
+
+	return backendError(tx.Commit())
+}
+
+// DeleteMulti deletes the keys in one statement
+func (s *SQLCache[T]) DeleteMulti(ctx context.Context, keys []string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	args := make([]any, 0, len(keys))
+	placeholders := make([]string, 0, len(keys))
+	for index, key := range keys {
+		key = s.config.key(key)
+		args = append(args, key)
+		placeholders = append(placeholders, s.placeholder(index+1))
+	}
+
+	query := fmt.Sprintf(`DELETE FROM %s WHERE cache_key IN (%s)`, s.options.Table, strings.Join(placeholders, ", "))
+	_, err := s.db.ExecContext(ctx, query, args...)
+	return backendError(err)
+}
+
+// Purge deletes the expired rows and returns how many were deleted
+func (s *SQLCache[T]) Purge(ctx context.Context) (int64, error) {
+	query := fmt.Sprintf(`DELETE FROM %s WHERE expires_at <= %s`, s.options.Table, s.placeholder(1))
+
+	result, err := s.db.ExecContext(ctx, query, time.Now().UnixMilli())
+	if err != nil {
+		return 0, backendError(err)
+	}
+
+	return result.RowsAffected()
+}
+
+// Close stops the periodic purge, the database is left open
+func (s *SQLCache[T]) Close() {
+	s.closeOnce.Do(func() {
+		close(s.stop)
+		<-s.stopped
+	})
+}
+
+// run purges the expired rows periodically
+func (s *SQLCache[T]) run() {
+	defer close(s.stopped)
+
+	ticker := time.NewTicker(s.options.PurgeInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			s.Purge(context.Background())
+		}
+	}
+}
+
+// upsertQuery returns the statement inserting or replacing a row
+func (s *SQLCache[T]) upsertQuery() string {
+	if s.options.Dialect == MySQL {
+		return fmt.Sprintf(`INSERT INTO %s (cache_key, cache_value, expires_at) VALUES (?, ?, ?) `+
+			`ON DUPLICATE KEY UPDATE cache_value = VALUES(cache_value), expires_at = VALUES(expires_at)`, s.options.Table)
+	}
+
+	return fmt.Sprintf(`INSERT INTO %s (cache_key, cache_value, expires_at) VALUES (%s, %s, %s) `+
+		`ON CONFLICT (cache_key) DO UPDATE SET cache_value = excluded.cache_value, expires_at = excluded.expires_at`,
+		s.options.Table, s.placeholder(1), s.placeholder(2), s.placeholder(3))
+}
+
+// placeholder returns the placeholder of the nth argument, starting from 1
+func (s *SQLCache[T]) placeholder(n int) string {
+	if s.options.Dialect == Postgres {
+		return "$" + strconv.Itoa(n)
+	}
+
+	return "?"
+}
+
+// randomExpiration returns the expiration randomized by expiryDeviation
+func (s *SQLCache[T]) randomExpiration() time.Duration {
+	// interval [0.95, 1.05)
+	deviation := 1.0 - s.expiryDeviation + rand.Float64()*s.expiryDeviation*2
+	return time.Duration(float64(s.expiration) * deviation)
+}
@@ -0,0 +1,175 @@
+package gocache
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func newTestSQLCache[T any](t *testing.T, expiration time.Duration, opts SQLCacheOptions, options ...CacheOption) (*SQLCache[T], *sql.DB) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("sql.Open() error = %v", err)
+	}
+	// a single connection keeps the in-memory database alive and private
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	// the sqlite3 driver is a stub when built without cgo
+	if err := db.Ping(); err != nil {
+		if strings.Contains(err.Error(), "requires cgo") {
+			t.Skipf("sqlite3 not available, skip: %v", err)
+		}
+		t.Fatalf("sql.DB.Ping() error = %v", err)
+	}
+
+	sc := NewSQLCache[T](db, expiration, opts, options...)
+	t.Cleanup(sc.Close)
+
+	if err := sc.CreateTable(context.Background()); err != nil {
+		t.Fatalf("SQLCache.CreateTable() error = %v", err)
+	}
+
+	return sc, db
+}
+
+func TestSQLCache_SetGetDelete(t *testing.T) {
+	ctx := context.Background()
+	sc, _ := newTestSQLCache[*getResponse](t, time.Minute, SQLCacheOptions{}, WithKeyPrefix("sql:"))
+
+	if _, err := sc.Get(ctx, "k1"); err != ErrRecordNotFound {
+		t.Errorf("SQLCache.Get() error = %v, want = %v", err, ErrRecordNotFound)
+	}
+
+	sc.Set(ctx, "k1", &getResponse{Value: 1})
+	// upsert
+	if err := sc.Set(ctx, "k1", &getResponse{Value: 2}); err != nil {
+		t.Fatalf("SQLCache.Set() error = %v", err)
+	}
+
+	got, err := sc.Get(ctx, "k1")
+	if err != nil || got.Value != 2 {
+		t.Errorf("SQLCache.Get() got = %v, error = %v, want = %v", got, err, 2)
+	}
+
+	if err := sc.Delete(ctx, "k1"); err != nil {
+		t.Errorf("SQLCache.Delete() error = %v", err)
+	}
+
+	if _, err := sc.Get(ctx, "k1"); err != ErrRecordNotFound {
+		t.Errorf("SQLCache.Get() after delete error = %v, want = %v", err, ErrRecordNotFound)
+	}
+
+	// creating the table again is a no-op
+	if err := sc.CreateTable(ctx); err != nil {
+		t.Errorf("SQLCache.CreateTable() error = %v", err)
+	}
+}
+
+func TestSQLCache_Expiration(t *testing.T) {
+	ctx := context.Background()
+	sc, db := newTestSQLCache[string](t, 100*time.Millisecond, SQLCacheOptions{Table: "expiring", PurgeInterval: -1})
+
+	sc.Set(ctx, "k1", "v1")
+	time.Sleep(200 * time.Millisecond)
+
+	// expired rows are not returned before they are purged
+	if _, err := sc.Get(ctx, "k1"); err != ErrRecordNotFound {
+		t.Errorf("SQLCache.Get() expired error = %v, want = %v", err, ErrRecordNotFound)
+	}
+
+	purged, err := sc.Purge(ctx)
+	if err != nil || purged != 1 {
+		t.Errorf("SQLCache.Purge() got = %v, error = %v, want = %v", purged, err, 1)
+	}
+
+	var count int
+	db.QueryRow("SELECT COUNT(*) FROM expiring").Scan(&count)
+	if count != 0 {
+		t.Errorf("rows after purge got = %v, want = %v", count, 0)
+	}
+}
+
+func TestSQLCache_PurgeLoop(t *testing.T) {
+	ctx := context.Background()
+	sc, db := newTestSQLCache[string](t, 50*time.Millisecond, SQLCacheOptions{PurgeInterval: 50 * time.Millisecond})
+
+	sc.Set(ctx, "k1", "v1")
+	time.Sleep(300 * time.Millisecond)
+
+	var count int
+	db.QueryRow("SELECT COUNT(*) FROM gocache").Scan(&count)
+	if count != 0 {
+		t.Errorf("rows after purge got = %v, want = %v", count, 0)
+	}
+}
+
+func TestSQLCache_Multi(t *testing.T) {
+	ctx := context.Background()
+	sc, _ := newTestSQLCache[int](t, time.Minute, SQLCacheOptions{}, WithKeyPrefix("multi:"))
+
+	if err := sc.SetMulti(ctx, map[string]int{"k1": 1, "k2": 2, "k3": 3}); err != nil {
+		t.Fatalf("SQLCache.SetMulti() error = %v", err)
+	}
+
+	got, err := sc.GetMulti(ctx, []string{"k1", "k2", "k4"})
+	if err != nil || len(got) != 2 || got["k1"] != 1 || got["k2"] != 2 {
+		t.Errorf("SQLCache.GetMulti() got = %v, error = %v, want = %v", got, err, map[string]int{"k1": 1, "k2": 2})
+	}
+
+	if err := sc.DeleteMulti(ctx, []string{"k1", "k3"}); err != nil {
+		t.Fatalf("SQLCache.DeleteMulti() error = %v", err)
+	}
+
+	got, err = sc.GetMulti(ctx, []string{"k1", "k2", "k3"})
+	if err != nil || len(got) != 1 || got["k2"] != 2 {
+		t.Errorf("SQLCache.GetMulti() after delete got = %v, error = %v, want = %v", got, err, map[string]int{"k2": 2})
+	}
+}
+
+func TestSQLCache_Dialect(t *testing.T) {
+	tests := []struct {
+		name    string
+		dialect SQLDialect
+		want    string
+	}{
+		{"sqlite", SQLite, "INSERT INTO cache (cache_key, cache_value, expires_at) VALUES (?, ?, ?) ON CONFLICT (cache_key) DO UPDATE SET cache_value = excluded.cache_value, expires_at = excluded.expires_at"},
+		{"postgres", Postgres, "INSERT INTO cache (cache_key, cache_value, expires_at) VALUES ($1, $2, $3) ON CONFLICT (cache_key) DO UPDATE SET cache_value = excluded.cache_value, expires_at = excluded.expires_at"},
+		{"mysql", MySQL, "INSERT INTO cache (cache_key, cache_value, expires_at) VALUES (?, ?, ?) ON DUPLICATE KEY UPDATE cache_value = VALUES(cache_value), expires_at = VALUES(expires_at)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sc := NewSQLCache[string](nil, time.Minute, SQLCacheOptions{Table: "cache", Dialect: tt.dialect, PurgeInterval: -1})
+			if got := sc.upsertQuery(); got != tt.want {
+				t.Errorf("SQLCache.upsertQuery() got = %v, want = %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSQLCache_Multi_HashedKeys(t *testing.T) {
+	ctx := context.Background()
+	sc, _ := newTestSQLCache[int](t, time.Minute, SQLCacheOptions{}, WithKeyPrefix("multi:"), WithMaxKeyLength(50))
+
+	long := strings.Repeat("k", 100)
+	if err := sc.SetMulti(ctx, map[string]int{"k1": 1, long: 2}); err != nil {
+		t.Fatalf("SQLCache.SetMulti() error = %v", err)
+	}
+
+	// the batch results are keyed by the keys of the caller
+	got, err := sc.GetMulti(ctx, []string{"k1", long})
+	if err != nil || len(got) != 2 || got["k1"] != 1 || got[long] != 2 {
+		t.Errorf("SQLCache.GetMulti() got = %v, error = %v", got, err)
+	}
+
+	if err := sc.DeleteMulti(ctx, []string{long}); err != nil {
+		t.Fatalf("SQLCache.DeleteMulti() error = %v", err)
+	}
+	if _, err := sc.Get(ctx, long); err != ErrRecordNotFound {
+		t.Errorf("SQLCache.Get() after delete error = %v, want = %v", err, ErrRecordNotFound)
+	}
+}
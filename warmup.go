@@ -0,0 +1,120 @@
+package gocache
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+type WarmupOptions struct {
+	// number of keys loaded at the same time, default 8
+	Parallelism int
+	// called after each key is loaded, with the number of keys done so far,
+	// the number of failed ones among them and the total number of keys
+	Progress func(done, failed, total int)
+}
+
+// WarmupResult counts the keys loaded by a warmup
+type WarmupResult struct {
+	Total  int
+	Loaded int
+	Failed int
+}
+
+// Warmup loads the given keys into the cache, keys already in cache are not
+// loaded again. The failures of single keys are counted in the result, the
+// returned error is only set if the context is done before all the keys are
+// loaded.
+func (c *LoadableCache[T, K]) Warmup(ctx context.Context, args []T, fn LoadFunctionCtx[T, K], opts WarmupOptions) (WarmupResult, error) {
+	parallelism := opts.Parallelism
+	if parallelism <= 0 {
+		parallelism = 8
+	}
+
+	result := WarmupResult{Total: len(args)}
+	lock := sync.Mutex{}
+	wg := &sync.WaitGroup{}
+	slots := make(chan struct{}, parallelism)
+
+	var err error
+	for _, arg := range args {
+		select {
+		case <-ctx.Done():
+			err = ctx.Err()
+		case slots <- struct{}{}:
+		}
+		if err != nil {
+			break
+		}
+
+		wg.Add(1)
+		go func(arg T) {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+
+			_, loadErr := c.LoadCtx(ctx, fn, arg)
+
+			lock.Lock()
+			defer lock.Unlock()
+
+			if loadErr != nil {
+				result.Failed++
+			} else {
+				result.Loaded++
+			}
+
+			if opts.Progress != nil {
+				opts.Progress(result.Loaded+result.Failed, result.Failed, result.Total)
+			}
+		}(arg)
+	}
+
+	wg.Wait()
+
+	return result, err
+}
+
+// WarmupFromReader warms up the cache with the keys read from r, one key per
+// line. Each non-empty line is converted to a key by parse.
+func (c *LoadableCache[T, K]) WarmupFromReader(ctx context.Context, r io.Reader, parse func(line string) (T, error), fn LoadFunctionCtx[T, K], opts WarmupOptions) (WarmupResult, error) {
+	var args []T
+
+	scanner := bufio.NewScanner(r)
+	for number := 1; scanner.Scan(); number++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		arg, err := parse(line)
+		if err != nil {
+			return WarmupResult{}, fmt.Errorf("failed to parse warmup key at line %d: %w", number, err)
+		}
+
+		args = append(args, arg)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return WarmupResult{}, err
+	}
+
+	return c.Warmup(ctx, args, fn, opts)
+}
+
+// WarmupFromFile warms up the cache with the keys read from the file at path,
+// one key per line
+func (c *LoadableCache[T, K]) WarmupFromFile(ctx context.Context, path string, parse func(line string) (T, error), fn LoadFunctionCtx[T, K], opts WarmupOptions) (WarmupResult, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return WarmupResult{}, err
+	}
+	defer file.Close()
+
+	return c.WarmupFromReader(ctx, file, parse, fn, opts)
+}
@@ -0,0 +1,89 @@
+package gocache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLoadableCache_Warmup(t *testing.T) {
+	ctx := context.Background()
+	ms := NewMemoryCache[string](time.Minute)
+	lc := NewLoadableCache[int, string](ms)
+
+	var running, peak int64
+	fn := func(ctx context.Context, arg int) (string, error) {
+		current := atomic.AddInt64(&running, 1)
+		defer atomic.AddInt64(&running, -1)
+		for {
+			old := atomic.LoadInt64(&peak)
+			if current <= old || atomic.CompareAndSwapInt64(&peak, old, current) {
+				break
+			}
+		}
+
+		time.Sleep(10 * time.Millisecond)
+		if arg == 7 {
+			return "", errors.New("load failed")
+		}
+		return fmt.Sprintf("v%d", arg), nil
+	}
+
+	var lastDone, lastFailed, lastTotal int
+	result, err := lc.Warmup(ctx, []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}, fn, WarmupOptions{
+		Parallelism: 3,
+		Progress: func(done, failed, total int) {
+			lastDone, lastFailed, lastTotal = done, failed, total
+		},
+	})
+	if err != nil {
+		t.Errorf("LoadableCache.Warmup() error = %v", err)
+	}
+
+	want := WarmupResult{Total: 10, Loaded: 9, Failed: 1}
+	if result != want {
+		t.Errorf("LoadableCache.Warmup() got = %+v, want = %+v", result, want)
+	}
+	if lastDone != 10 || lastFailed != 1 || lastTotal != 10 {
+		t.Errorf("progress got = %v/%v/%v, want = 10/1/10", lastDone, lastFailed, lastTotal)
+	}
+	if peak > 3 {
+		t.Errorf("concurrent loads peak = %v, want <= %v", peak, 3)
+	}
+
+	if got, _ := ms.Get(ctx, GenerateCacheKey(5)); got != "v5" {
+		t.Errorf("MemoryCache.Get() got = %v, want = %v", got, "v5")
+	}
+}
+
+func TestLoadableCache_WarmupFromFile(t *testing.T) {
+	ctx := context.Background()
+	lc := NewLoadableCache[int, string](NewMemoryCache[string](time.Minute))
+
+	path := filepath.Join(t.TempDir(), "keys.txt")
+	if err := os.WriteFile(path, []byte("1\n2\n\n3\n"), 0o644); err != nil {
+		t.Fatalf("failed to write keys file: %v", err)
+	}
+
+	fn := func(ctx context.Context, arg int) (string, error) {
+		return strconv.Itoa(arg), nil
+	}
+
+	result, err := lc.WarmupFromFile(ctx, path, strconv.Atoi, fn, WarmupOptions{})
+	if err != nil || result.Loaded != 3 {
+		t.Errorf("LoadableCache.WarmupFromFile() got = %+v, error = %v", result, err)
+	}
+
+	// a malformed key aborts the warmup before anything is loaded
+	_, err = lc.WarmupFromReader(ctx, strings.NewReader("1\nx\n"), strconv.Atoi, fn, WarmupOptions{})
+	if err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("LoadableCache.WarmupFromReader() error = %v, want a line 2 error", err)
+	}
+}
@@ -0,0 +1,187 @@
+package gocache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+var ErrClosed = errors.New("cache closed")
+
+// SaveFunction writes a value to the backing store
+type SaveFunction[T, K any] func(ctx context.Context, arg T, value K) error
+
+// BatchSaveFunction writes several values to the backing store at once
+type BatchSaveFunction[T, K any] func(ctx context.Context, entries []WriteEntry[T, K]) error
+
+// WriteEntry is a value waiting to be written to the backing store
+type WriteEntry[T, K any] struct {
+	Arg   T
+	Value K
+}
+
+type WriteBehindConfig struct {
+	// flush the pending writes at this interval, default 1s
+	Interval time.Duration
+	// flush as soon as this many keys have pending writes, default 100
+	BatchSize int
+	// called with the errors of the background flushes, the writes of a
+	// failed flush are retried with the next one
+	OnError func(err error)
+}
+
+// writeBehind buffers writes and flushes them in batches, writes of the same
+// key are coalesced so that only the last one is flushed
+type writeBehind[T, K any] struct {
+	config WriteBehindConfig
+	save   BatchSaveFunction[T, K]
+
+	lock    sync.Mutex
+	pending map[string]WriteEntry[T, K]
+	// keys of the pending writes in the order of their first write
+	order  []string
+	closed bool
+
+	// serializes the flushes, so that writes of a key are never reordered
+	flushLock sync.Mutex
+	trigger   chan struct{}
+	stop      chan struct{}
+	stopped   chan struct{}
+	// cancels the background flush running when the writeBehind is closed
+	cancel context.CancelFunc
+}
+
+func newWriteBehind[T, K any](config WriteBehindConfig, save BatchSaveFunction[T, K]) *writeBehind[T, K] {
+	if config.Interval <= 0 {
+		config.Interval = time.Second
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = 100
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	w := &writeBehind[T, K]{
+		config:  config,
+		save:    save,
+		pending: make(map[string]WriteEntry[T, K]),
+		trigger: make(chan struct{}, 1),
+		stop:    make(chan struct{}),
+		stopped: make(chan struct{}),
+		cancel:  cancel,
+	}
+
+	go w.run(ctx)
+
+	return w
+}
+
+// add buffers the write of the key, replacing its pending write if any
+func (w *writeBehind[T, K]) add(key string, entry WriteEntry[T, K]) error {
+	w.lock.Lock()
+	if w.closed {
+		w.lock.Unlock()
+		return ErrClosed
+	}
+
+	if _, found := w.pending[key]; !found {
+		w.order = append(w.order, key)
+	}
+	w.pending[key] = entry
+	full := len(w.pending) >= w.config.BatchSize
+	w.lock.Unlock()
+
+	if full {
+		select {
+		case w.trigger <- struct{}{}:
+		default:
+		}
+	}
+
+	return nil
+}
+
+// flush writes all the pending writes. The writes of a failed flush are put
+// back unless their key has been written again in the meantime
+func (w *writeBehind[T, K]) flush(ctx context.Context) error {
+	w.flushLock.Lock()
+	defer w.flushLock.Unlock()
+
+	w.lock.Lock()
+	pending, order := w.pending, w.order
+	w.pending = make(map[string]WriteEntry[T, K])
+	w.order = nil
+	w.lock.Unlock()
+
+	if len(order) == 0 {
+		return nil
+	}
+
+	entries := make([]WriteEntry[T, K], 0, len(order))
+	for _, key := range order {
+		entries = append(entries, pending[key])
+	}
+
+	err := w.save(ctx, entries)
+	if err == nil {
+		return nil
+	}
+
+	w.lock.Lock()
+	requeued := make([]string, 0, len(order)+len(w.order))
+	for _, key := range order {
+		if _, found := w.pending[key]; !found {
+			w.pending[key] = pending[key]
+			requeued = append(requeued, key)
+		}
+	}
+	w.order = append(requeued, w.order...)
+	w.lock.Unlock()
+
+	return err
+}
+
+// run flushes the pending writes periodically or when the batch is full
+func (w *writeBehind[T, K]) run(ctx context.Context) {
+	defer close(w.stopped)
+
+	ticker := time.NewTicker(w.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-ticker.C:
+		case <-w.trigger:
+		}
+
+		err := w.flush(ctx)
+		// the writes of a flush cancelled by close are drained by close
+		if err != nil && ctx.Err() == nil && w.config.OnError != nil {
+			w.config.OnError(err)
+		}
+	}
+}
+
+// close stops the background flushes and drains the pending writes, new
+// writes are rejected with ErrClosed. A running background flush is
+// cancelled, if it doesn't return before ctx is done close gives up without
+// draining.
+func (w *writeBehind[T, K]) close(ctx context.Context) error {
+	w.lock.Lock()
+	if !w.closed {
+		w.closed = true
+		close(w.stop)
+		w.cancel()
+	}
+	w.lock.Unlock()
+
+	select {
+	case <-w.stopped:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	return w.flush(ctx)
+}
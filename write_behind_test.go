@@ -0,0 +1,259 @@
+package gocache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// store is a backing store recording its writes
+type store struct {
+	lock    sync.Mutex
+	data    map[string]string
+	batches int
+	err     error
+}
+
+func newStore() *store {
+	return &store{data: make(map[string]string)}
+}
+
+func (s *store) Save(ctx context.Context, key, value string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.err != nil {
+		return s.err
+	}
+
+	s.data[key] = value
+	return nil
+}
+
+func (s *store) SaveBatch(ctx context.Context, entries []WriteEntry[string, string]) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.err != nil {
+		return s.err
+	}
+
+	s.batches++
+	for _, entry := range entries {
+		s.data[entry.Arg] = entry.Value
+	}
+	return nil
+}
+
+func (s *store) Get(key string) string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.data[key]
+}
+
+func TestLoadableCache_StoreWriteThrough(t *testing.T) {
+	ctx := context.Background()
+	s := newStore()
+	ms := NewMemoryCache[string](time.Minute)
+	lc := NewLoadableCache[string, string](ms, WithSaveFunction(s.Save))
+
+	if err := lc.Store(ctx, "k1", "v1"); err != nil {
+		t.Errorf("LoadableCache.Store() error = %v", err)
+	}
+
+	if got := s.Get("k1"); got != "v1" {
+		t.Errorf("store got = %v, want = %v", got, "v1")
+	}
+	if got, _ := ms.Get(ctx, "k1"); got != "v1" {
+		t.Errorf("MemoryCache.Get() got = %v, want = %v", got, "v1")
+	}
+
+	// a failed write leaves the cache untouched
+	s.err = errors.New("store unavailable")
+	if err := lc.Store(ctx, "k1", "v2"); err != s.err {
+		t.Errorf("LoadableCache.Store() error = %v, want = %v", err, s.err)
+	}
+	if got, _ := ms.Get(ctx, "k1"); got != "v1" {
+		t.Errorf("MemoryCache.Get() got = %v, want = %v", got, "v1")
+	}
+
+	// without a save function there is nowhere to write to
+	lc = NewLoadableCache[string, string](ms)
+	if err := lc.Store(ctx, "k1", "v2"); err != ErrNotSupported {
+		t.Errorf("LoadableCache.Store() error = %v, want = %v", err, ErrNotSupported)
+	}
+}
+
+func TestLoadableCache_StoreWriteBehind(t *testing.T) {
+	ctx := context.Background()
+	s := newStore()
+	ms := NewMemoryCache[string](time.Minute)
+	lc := NewLoadableCache[string, string](ms,
+		WithBatchSaveFunction(s.SaveBatch),
+		WithWriteBehind(WriteBehindConfig{Interval: time.Hour, BatchSize: 3}),
+	)
+
+	// writes of the same key are coalesced
+	for _, value := range []string{"v1", "v2", "v3"} {
+		if err := lc.Store(ctx, "k1", value); err != nil {
+			t.Errorf("LoadableCache.Store() error = %v", err)
+		}
+	}
+	if err := lc.Store(ctx, "k2", "v1"); err != nil {
+		t.Errorf("LoadableCache.Store() error = %v", err)
+	}
+
+	// the cache is updated immediately, the store on flush
+	if got, _ := ms.Get(ctx, "k1"); got != "v3" {
+		t.Errorf("MemoryCache.Get() got = %v, want = %v", got, "v3")
+	}
+	if got := s.Get("k1"); got != "" {
+		t.Errorf("store got = %v before flush, want empty", got)
+	}
+
+	if err := lc.Flush(ctx); err != nil {
+		t.Errorf("LoadableCache.Flush() error = %v", err)
+	}
+	if got := s.Get("k1"); got != "v3" || s.batches != 1 {
+		t.Errorf("store got = %v, batches = %v", got, s.batches)
+	}
+
+	// reaching the batch size triggers a flush
+	for _, key := range []string{"k3", "k4", "k5"} {
+		lc.Store(ctx, key, "v1")
+	}
+	deadline := time.Now().Add(time.Second)
+	for s.Get("k5") == "" && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := s.Get("k5"); got != "v1" {
+		t.Errorf("store got = %v after a full batch, want = %v", got, "v1")
+	}
+
+	// Close drains the pending writes and rejects the new ones
+	lc.Store(ctx, "k6", "v1")
+	if err := lc.Close(ctx); err != nil {
+		t.Errorf("LoadableCache.Close() error = %v", err)
+	}
+	if got := s.Get("k6"); got != "v1" {
+		t.Errorf("store got = %v after Close, want = %v", got, "v1")
+	}
+	if err := lc.Store(ctx, "k7", "v1"); err != ErrClosed {
+		t.Errorf("LoadableCache.Store() after Close error = %v, want = %v", err, ErrClosed)
+	}
+}
+
+func TestWriteBehind_FlushFailure(t *testing.T) {
+	ctx := context.Background()
+	s := newStore()
+	s.err = errors.New("store unavailable")
+
+	w := newWriteBehind(WriteBehindConfig{Interval: time.Hour}, s.SaveBatch)
+	defer w.close(ctx)
+
+	w.add("k1", WriteEntry[string, string]{Arg: "k1", Value: "v1"})
+	w.add("k2", WriteEntry[string, string]{Arg: "k2", Value: "v1"})
+
+	if err := w.flush(ctx); err != s.err {
+		t.Errorf("writeBehind.flush() error = %v, want = %v", err, s.err)
+	}
+
+	// a newer write of k1 wins over the failed one
+	w.add("k1", WriteEntry[string, string]{Arg: "k1", Value: "v2"})
+
+	s.err = nil
+	if err := w.flush(ctx); err != nil {
+		t.Errorf("writeBehind.flush() error = %v", err)
+	}
+
+	if got := s.Get("k1"); got != "v2" {
+		t.Errorf("store got = %v, want = %v", got, "v2")
+	}
+	if got := s.Get("k2"); got != "v1" {
+		t.Errorf("store got = %v, want = %v", got, "v1")
+	}
+}
+
+func TestWriteBehind_CloseDuringFlush(t *testing.T) {
+	flushing := make(chan struct{})
+
+	var lock sync.Mutex
+	var saved []WriteEntry[string, string]
+	var onError []error
+	w := newWriteBehind(WriteBehindConfig{
+		Interval:  time.Hour,
+		BatchSize: 1,
+		OnError: func(err error) {
+			lock.Lock()
+			onError = append(onError, err)
+			lock.Unlock()
+		},
+	}, func(ctx context.Context, entries []WriteEntry[string, string]) error {
+		select {
+		case flushing <- struct{}{}:
+			// the background flush waits until it is cancelled
+			<-ctx.Done()
+			return ctx.Err()
+		default:
+		}
+
+		lock.Lock()
+		saved = append(saved, entries...)
+		lock.Unlock()
+		return nil
+	})
+
+	w.add("k1", WriteEntry[string, string]{Arg: "k1", Value: "v1"})
+	<-flushing
+
+	// close cancels the background flush and drains its writes
+	if err := w.close(context.Background()); err != nil {
+		t.Errorf("writeBehind.close() error = %v", err)
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+	if len(saved) != 1 || saved[0].Value != "v1" {
+		t.Errorf("writeBehind.close() saved = %v, want = %v", saved, "[{k1 v1}]")
+	}
+	if len(onError) != 0 {
+		t.Errorf("WriteBehindConfig.OnError() got = %v, want none", onError)
+	}
+}
+
+func TestWriteBehind_CloseTimeout(t *testing.T) {
+	flushing := make(chan struct{})
+	unblock := make(chan struct{})
+	defer close(unblock)
+
+	w := newWriteBehind(WriteBehindConfig{Interval: time.Hour, BatchSize: 1},
+		func(ctx context.Context, entries []WriteEntry[string, string]) error {
+			// a save ignoring its context
+			close(flushing)
+			<-unblock
+			return nil
+		})
+
+	w.add("k1", WriteEntry[string, string]{Arg: "k1", Value: "v1"})
+	<-flushing
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- w.close(ctx)
+	}()
+
+	select {
+	case err := <-done:
+		if err != context.DeadlineExceeded {
+			t.Errorf("writeBehind.close() error = %v, want = %v", err, context.DeadlineExceeded)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("writeBehind.close() did not return when its context was done")
+	}
+}